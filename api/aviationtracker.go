@@ -19,212 +19,129 @@ func formatTime(t time.Time) string {
 }
 
 type MigrateInterface interface {
-	MigrateAirlineAPIData() error
-	MigrateAircraftAPIData() error
-	MigrateTaxAPIData() error
-	MigrateAirplaneAPIData() error
-	MigrateAirportAPIData() error
-	MigrateCountryAPIData() error
-	MigrateCityAPIData() error
-	MigrateFlightAPIData() error
+	MigrateAirlineAPIData(ctx context.Context) error
+	MigrateAircraftAPIData(ctx context.Context) error
+	MigrateTaxAPIData(ctx context.Context) error
+	MigrateAirplaneAPIData(ctx context.Context) error
+	MigrateAirportAPIData(ctx context.Context) error
+	MigrateCountryAPIData(ctx context.Context) error
+	MigrateCityAPIData(ctx context.Context) error
+	MigrateRouteAPIData(ctx context.Context) error
+	MigrateFlightAPIData(ctx context.Context) error
+	MigrateOurAirportsData(ctx context.Context) error
 }
 
+// MigrateRepository seeds reference data from provider, the flights
+// matching flightsQuery from the AviationStack client and, when ourAirports
+// is set, runways, frequencies and navaids from the OurAirports dumps. Each table is seeded until its
+// checkpoint says it completed.
 type MigrateRepository struct {
-	conn *pgxpool.Pool
+	conn        *pgxpool.Pool
+	client      *AviationStackClient
+	provider    ReferenceProvider
+	ourAirports *OurAirportsProvider
+	// flightsQuery narrows the flights seeded, as it does the flights poll
+	flightsQuery []string
+	checkpoints  *SeedCheckpoints
 }
 
-func NewRepository(conn *pgxpool.Pool) MigrateInterface {
-	return &MigrateRepository{conn: conn}
-}
-
-/*Airline Migration function */
-
-func (m *MigrateRepository) MigrateAirlineAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM airline").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
-
-	if count == 0 {
-		// No data in the airline table, fetch from the external API
-		if err := FetchAndInsertAirlineData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
+func NewRepository(
+	conn *pgxpool.Pool,
+	client *AviationStackClient,
+	provider ReferenceProvider,
+	ourAirports *OurAirportsProvider,
+	flightsQuery []string,
+) MigrateInterface {
+	return &MigrateRepository{
+		conn:         conn,
+		client:       client,
+		provider:     provider,
+		ourAirports:  ourAirports,
+		flightsQuery: flightsQuery,
+		checkpoints:  NewSeedCheckpoints(conn),
 	}
-	slog.Info("Migrations finished")
-	return nil
 }
 
-/*Aircraft Migration function */
-
-func (m *MigrateRepository) MigrateAircraftAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM aircraft").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
+// seed seeds dataset with load, see SeedCheckpoints.Seed.
+func (m *MigrateRepository) seed(ctx context.Context, dataset string, load func(ctx context.Context) error) error {
+	if err := m.checkpoints.Seed(ctx, dataset, load); err != nil {
+		handleError(err, "Error inserting "+dataset+" data")
 		return err
 	}
-
-	if count == 0 {
-		// No data in the airline table, fetch from the external API
-		if err := FetchAndInsertAircraftData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
-	}
-	slog.Info("Migrations finished")
+	slog.Info("Migrations finished", "dataset", dataset)
 	return nil
 }
 
-/*Tax Migration function */
-
-func (m *MigrateRepository) MigrateTaxAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM tax").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
-
-	if count == 0 {
-		// No data in the airline table, fetch from the external API
-		if err := FetchAndInsertTaxData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
+// fromProvider returns a load seeding a reference table from m.provider.
+func (m *MigrateRepository) fromProvider(
+	insert func(context.Context, *pgxpool.Pool, ReferenceProvider) error,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return insert(ctx, m.conn, m.provider)
 	}
-	slog.Info("Migrations finished")
-	return nil
 }
 
-/* Airplane */
-
-func (m *MigrateRepository) MigrateAirplaneAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM airplane").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
-
-	if count == 0 {
-		// No data in the airline table, fetch from the external API
-		if err := FetchAndInsertAirplaneData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
-	}
-	slog.Info("Migrations finished")
-	return nil
+func (m *MigrateRepository) MigrateAirlineAPIData(ctx context.Context) error {
+	return m.seed(ctx, "airline", m.fromProvider(FetchAndInsertAirlineData))
 }
 
-/* Airports */
-
-func (m *MigrateRepository) MigrateAirportAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM airport").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
-
-	if count == 0 {
-		// No data in the airport table, fetch from the external API
-		if err := FetchAndInsertAirportData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
-	}
-	slog.Info("Migrations finished")
-	return nil
+func (m *MigrateRepository) MigrateAircraftAPIData(ctx context.Context) error {
+	return m.seed(ctx, "aircraft", m.fromProvider(FetchAndInsertAircraftData))
 }
 
-/* Countries */
-
-func (m *MigrateRepository) MigrateCountryAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
-
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM country").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
+func (m *MigrateRepository) MigrateTaxAPIData(ctx context.Context) error {
+	return m.seed(ctx, "tax", m.fromProvider(FetchAndInsertTaxData))
+}
 
-	if count == 0 {
-		// No data in the country table, fetch from the external API
-		if err := FetchAndInsertCountryData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
-	}
-	slog.Info("Migrations finished")
-	return nil
+func (m *MigrateRepository) MigrateAirplaneAPIData(ctx context.Context) error {
+	return m.seed(ctx, "airplane", m.fromProvider(FetchAndInsertAirplaneData))
 }
 
-/* Cities */
+func (m *MigrateRepository) MigrateAirportAPIData(ctx context.Context) error {
+	return m.seed(ctx, "airport", m.fromProvider(FetchAndInsertAirportData))
+}
 
-func (m *MigrateRepository) MigrateCityAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
+func (m *MigrateRepository) MigrateCountryAPIData(ctx context.Context) error {
+	return m.seed(ctx, "country", m.fromProvider(FetchAndInsertCountryData))
+}
 
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM city").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
-	}
+func (m *MigrateRepository) MigrateCityAPIData(ctx context.Context) error {
+	return m.seed(ctx, "city", m.fromProvider(FetchAndInsertCityData))
+}
 
-	if count == 0 {
-		// No data in the airport table, fetch from the external API
-		if err := FetchAndInsertCityData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
-			return err
-		}
-	}
-	slog.Info("Migrations finished")
-	return nil
+func (m *MigrateRepository) MigrateRouteAPIData(ctx context.Context) error {
+	return m.seed(ctx, "route", m.fromProvider(FetchAndInsertRouteData))
 }
 
-/* Flights */
+func (m *MigrateRepository) MigrateFlightAPIData(ctx context.Context) error {
+	return m.seed(ctx, "flights", func(ctx context.Context) error {
+		return FetchAndInsertFlightData(ctx, m.conn, m.client, m.flightsQuery...)
+	})
+}
 
-func (m *MigrateRepository) MigrateFlightAPIData() error {
-	slog.Info("Running API check")
-	ctx := context.Background()
-	slog.Info("checking for data on the DB")
+// MigrateOurAirportsData seeds runways, frequencies and navaids from the
+// OurAirports dumps, when they are configured.
+func (m *MigrateRepository) MigrateOurAirportsData(ctx context.Context) error {
+	if m.ourAirports == nil {
+		slog.Info("OURAIRPORTS_DIR not set, skipping runways, frequencies and navaids")
+		return nil
+	}
 
-	var count int
-	if err := m.conn.QueryRow(ctx, "SELECT COUNT(*) FROM flights").Scan(&count); err != nil {
-		handleError(err, "Error querying the table")
-		return err
+	tables := []struct {
+		name   string
+		insert func(context.Context, *pgxpool.Pool, *OurAirportsProvider) error
+	}{
+		{"runway", FetchAndInsertRunwayData},
+		{"airport_frequency", FetchAndInsertFrequencyData},
+		{"navaid", FetchAndInsertNavaidData},
 	}
 
-	if count == 0 {
-		// No data in the flights table, fetch from the external API
-		if err := FetchAndInsertFlightData(m.conn); err != nil {
-			handleError(err, "Error inserting data")
+	for _, table := range tables {
+		if err := m.seed(ctx, table.name, func(ctx context.Context) error {
+			return table.insert(ctx, m.conn, m.ourAirports)
+		}); err != nil {
 			return err
 		}
 	}
-	slog.Info("Migrations finished")
 	return nil
 }
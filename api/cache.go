@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/FACorreiaa/go-ollama/config"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotModified is returned by stageChanged when every page of a response
+// matches the one the last sync committed.
+var ErrNotModified = errors.New("response not modified since the last sync")
+
+const (
+	responseKeyPrefix = "aviationstack:response:"
+	syncedKeyPrefix   = "aviationstack:synced:"
+)
+
+// ResponseCache keeps AviationStack response bodies in Redis for a TTL per
+// endpoint, together with their content hash. The hashes of the pages a
+// sync job wrote are kept in one hash per table, without expiry, so an
+// unchanged response can be recognised after the body itself has expired;
+// each sync replaces the previous one's, so the table's hash stays the
+// size of one response.
+type ResponseCache struct {
+	redis      *redis.Client
+	ttls       map[string]time.Duration
+	defaultTTL time.Duration
+}
+
+// NewResponseCache returns the cache configured by cfg, or nil when caching
+// is disabled.
+func NewResponseCache(redisClient *redis.Client, cfg *config.AviationStackConfig) *ResponseCache {
+	if !cfg.CacheEnabled {
+		return nil
+	}
+	return &ResponseCache{redis: redisClient, ttls: cfg.CacheTTLs, defaultTTL: cfg.CacheDefaultTTL}
+}
+
+// cachedBody is a response body read into memory, with the cache key and
+// content hash stageChanged needs.
+type cachedBody struct {
+	*bytes.Reader
+	key  string
+	hash string
+}
+
+func (b *cachedBody) Close() error {
+	return nil
+}
+
+// cacheKey identifies a request by endpoint and query, without the access
+// key so rotating keys does not empty the cache.
+func cacheKey(endpoint string, queryParams []string) string {
+	query := url.Values{}
+	for _, param := range queryParams {
+		if name, value, ok := strings.Cut(param, "="); ok {
+			query.Set(name, value)
+		}
+	}
+	return endpoint + "?" + query.Encode()
+}
+
+func (c *ResponseCache) ttl(endpoint string) time.Duration {
+	if ttl, ok := c.ttls[endpoint]; ok {
+		return ttl
+	}
+	return c.defaultTTL
+}
+
+// get returns the cached body for key. Redis errors are logged and treated
+// as a miss.
+func (c *ResponseCache) get(ctx context.Context, key string) (*cachedBody, bool) {
+	fields, err := c.redis.HGetAll(ctx, responseKeyPrefix+key).Result()
+	if err != nil {
+		slog.Warn("Error reading AviationStack response cache", "key", key, "error", err)
+		return nil, false
+	}
+	body, ok := fields["body"]
+	if !ok {
+		return nil, false
+	}
+	return &cachedBody{Reader: bytes.NewReader([]byte(body)), key: key, hash: fields["hash"]}, true
+}
+
+// put caches body under key for the endpoint's TTL.
+func (c *ResponseCache) put(ctx context.Context, endpoint, key string, body []byte) *cachedBody {
+	sum := sha256.Sum256(body)
+	cached := &cachedBody{Reader: bytes.NewReader(body), key: key, hash: hex.EncodeToString(sum[:])}
+
+	ttl := c.ttl(endpoint)
+	if ttl <= 0 {
+		return cached
+	}
+	if _, err := c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, responseKeyPrefix+key, "body", body, "hash", cached.hash)
+		pipe.Expire(ctx, responseKeyPrefix+key, ttl)
+		return nil
+	}); err != nil {
+		slog.Warn("Error writing AviationStack response cache", "key", key, "error", err)
+	}
+	return cached
+}
+
+// synced reports whether pages are exactly the pages last committed for
+// table: the same cache keys with the same hashes.
+func (c *ResponseCache) synced(ctx context.Context, table string, pages map[string]string) bool {
+	if len(pages) == 0 {
+		return false
+	}
+
+	committed, err := c.redis.HGetAll(ctx, syncedKeyPrefix+table).Result()
+	if err != nil {
+		slog.Warn("Error reading synced AviationStack hashes", "table", table, "error", err)
+		return false
+	}
+	return maps.Equal(committed, pages)
+}
+
+// commit records pages as the ones written to table, replacing those of the
+// previous sync so the hash holds one field per page of the latest response.
+func (c *ResponseCache) commit(ctx context.Context, table string, pages map[string]string) {
+	if len(pages) == 0 {
+		return
+	}
+
+	values := make([]any, 0, 2*len(pages))
+	for key, hash := range pages {
+		values = append(values, key, hash)
+	}
+	if _, err := c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, syncedKeyPrefix+table)
+		pipe.HSet(ctx, syncedKeyPrefix+table, values...)
+		return nil
+	}); err != nil {
+		slog.Warn("Error saving synced AviationStack hashes", "table", table, "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	a := cacheKey("flights", []string{"offset=0", "dep_iata=LIS", "malformed"})
+	b := cacheKey("flights", []string{"dep_iata=LIS", "offset=0"})
+	if a != b || a != "flights?dep_iata=LIS&offset=0" {
+		t.Errorf("got keys %q and %q, want the same sorted query", a, b)
+	}
+}
+
+func TestResponseCacheSynced(t *testing.T) {
+	cache := &ResponseCache{redis: testRedis(t), defaultTTL: time.Hour}
+	ctx := context.Background()
+	pages := map[string]string{"cities?offset=0": "a", "cities?offset=100": "b"}
+
+	if cache.synced(ctx, "city", pages) {
+		t.Error("got pages synced before any commit")
+	}
+	cache.commit(ctx, "city", pages)
+	if !cache.synced(ctx, "city", pages) {
+		t.Error("got committed pages not synced")
+	}
+	if cache.synced(ctx, "country", pages) {
+		t.Error("got pages synced for another table")
+	}
+	if cache.synced(ctx, "city", map[string]string{"cities?offset=0": "a", "cities?offset=100": "c"}) {
+		t.Error("got a changed page synced")
+	}
+	if cache.synced(ctx, "city", map[string]string{"cities?offset=0": "a"}) {
+		t.Error("got a response with fewer pages synced")
+	}
+
+	// a commit replaces the pages of the previous one
+	cache.commit(ctx, "city", map[string]string{"cities?offset=0": "d"})
+	if n := cache.redis.HLen(ctx, syncedKeyPrefix+"city").Val(); n != 1 {
+		t.Errorf("got %d synced pages stored, want 1", n)
+	}
+	if keys := cache.redis.Keys(ctx, syncedKeyPrefix+"*").Val(); len(keys) != 1 {
+		t.Errorf("got synced keys %q, want one per table", keys)
+	}
+}
+
+func TestStageChangedNotModified(t *testing.T) {
+	pool := testPool(t)
+	cache := &ResponseCache{redis: testRedis(t), defaultTTL: time.Hour}
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	var body atomic.Pointer[string]
+	first := `{"pagination":{"offset":0,"limit":100,"count":2,"total":2},"data":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}`
+	body.Store(&first)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, *body.Load())
+	}, "a")
+	client.cache = cache
+
+	// stage stages the records endpoint and returns how many records it holds
+	stage := func() (staged, int, error) {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, `CREATE TEMP TABLE stage_test (id int, name text) ON COMMIT DROP`); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := stageChanged(ctx, tx, client, "stage_test", "records", []string{"id", "name"},
+			func(r streamRecord) []any { return []any{r.ID, r.Name} })
+		if err != nil {
+			return result, 0, err
+		}
+		var count int
+		if err := tx.QueryRow(ctx, "SELECT count(*) FROM "+result.table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return result, count, nil
+	}
+
+	synced, count, err := stage()
+	if err != nil || count != 2 {
+		t.Fatalf("got %d records staged and error %v, want 2", count, err)
+	}
+	if _, _, err := stage(); err != nil {
+		t.Errorf("got error %v before the first sync committed, want the records staged again", err)
+	}
+	synced.commit()
+
+	if _, _, err := stage(); !errors.Is(err, ErrNotModified) {
+		t.Errorf("got error %v, want ErrNotModified once the same pages were committed", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want the cached body reused", n)
+	}
+
+	// once the cached body expires a changed response is staged again
+	second := `{"pagination":{"offset":0,"limit":100,"count":1,"total":1},"data":[{"id":1,"name":"a"}]}`
+	body.Store(&second)
+	cache.redis.Del(ctx, responseKeyPrefix+cacheKey("records", []string{"offset=0", "limit=100"}))
+	if _, count, err := stage(); err != nil || count != 1 {
+		t.Errorf("got %d records staged and error %v, want the changed response", count, err)
+	}
+}
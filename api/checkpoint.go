@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// SeedCheckpoint is how far seeding a dataset got, as recorded in the
+// seed_checkpoint table. Every page before NextOffset is stored; Total is
+// the dataset size the last page reported.
+type SeedCheckpoint struct {
+	Dataset     string
+	NextOffset  int
+	Total       *int
+	CompletedAt *time.Time
+}
+
+// Completed reports whether the dataset was fully seeded.
+func (c SeedCheckpoint) Completed() bool {
+	return c.CompletedAt != nil
+}
+
+// SeedCheckpoints records seeding progress per dataset, so an interrupted
+// seed resumes where it stopped and a dataset only counts as seeded once it
+// completed.
+type SeedCheckpoints struct {
+	conn *pgxpool.Pool
+}
+
+func NewSeedCheckpoints(conn *pgxpool.Pool) *SeedCheckpoints {
+	return &SeedCheckpoints{conn: conn}
+}
+
+// Get returns the checkpoint of dataset, a zero one when it was never seeded.
+func (c *SeedCheckpoints) Get(ctx context.Context, dataset string) (SeedCheckpoint, error) {
+	checkpoint := SeedCheckpoint{Dataset: dataset}
+	err := c.conn.QueryRow(ctx,
+		`select next_offset, total, completed_at from seed_checkpoint where dataset = $1`, dataset,
+	).Scan(&checkpoint.NextOffset, &checkpoint.Total, &checkpoint.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return checkpoint, nil
+	}
+	return checkpoint, err
+}
+
+// Seed runs load for dataset unless it already completed. An AviationStack
+// load resumes at the checkpoint's offset and moves it on as pages are
+// stored. The dataset is marked complete once load returns nil; a provider
+// without the dataset leaves it to be tried again on the next start.
+func (c *SeedCheckpoints) Seed(ctx context.Context, dataset string, load func(ctx context.Context) error) error {
+	checkpoint, err := c.Get(ctx, dataset)
+	if err != nil {
+		handleError(err, "Error reading the seed checkpoint")
+		return err
+	}
+	if checkpoint.Completed() {
+		slog.Info("Dataset already seeded", "dataset", dataset, "completed_at", checkpoint.CompletedAt)
+		return nil
+	}
+	if checkpoint.NextOffset > 0 {
+		slog.Info("Resuming seeding", "dataset", dataset, "offset", checkpoint.NextOffset, "total", checkpoint.Total)
+	}
+
+	ctx = withPageProgress(ctx, checkpoint.NextOffset, func(ctx context.Context, next, total int) {
+		handleError(c.advance(ctx, dataset, next, total), "Error saving the seed checkpoint")
+	})
+	err = load(ctx)
+	if errors.Is(err, ErrDatasetUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.complete(ctx, dataset)
+}
+
+// advance records that every page of dataset before next is stored.
+func (c *SeedCheckpoints) advance(ctx context.Context, dataset string, next, total int) error {
+	_, err := c.conn.Exec(ctx, `
+		insert into seed_checkpoint (dataset, next_offset, total) values ($1, $2, $3)
+		on conflict (dataset) do update
+		set next_offset = greatest(seed_checkpoint.next_offset, excluded.next_offset),
+			total = excluded.total, updated_at = now()`,
+		dataset, next, total,
+	)
+	return err
+}
+
+func (c *SeedCheckpoints) complete(ctx context.Context, dataset string) error {
+	_, err := c.conn.Exec(ctx, `
+		insert into seed_checkpoint (dataset, completed_at) values ($1, now())
+		on conflict (dataset) do update set completed_at = now(), updated_at = now()`,
+		dataset,
+	)
+	return err
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/config"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AviationStackClient performs requests against the AviationStack API.
+// The base URL and the *http.Client are injectable so the client can be
+// pointed at a mock server in staging or at an httptest server in tests.
+type AviationStackClient struct {
+	baseURL     *url.URL
+	accessKeys  []string
+	quota       *QuotaTracker
+	nextKey     atomic.Uint32
+	userAgent   string
+	httpClient  *http.Client
+	requireKey  bool
+	pageSize    int
+	concurrency int
+	cache       *ResponseCache
+	quarantine  *Quarantine
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+}
+
+// NewAviationStackClient builds a client from cfg. When httpClient is nil a
+// client honouring the configured timeouts is created. In record and replay
+// mode its transport is wrapped to save or serve responses from disk.
+// Responses go through cache unless it is nil. With a quota tracker every
+// request is counted and keys are rotated as they run out; without one the
+// configured keys are used in turn. Records that fail to decode are stored
+// in quarantine and skipped; with a nil quarantine they fail the page.
+func NewAviationStackClient(
+	cfg *config.AviationStackConfig,
+	httpClient *http.Client,
+	cache *ResponseCache,
+	quota *QuotaTracker,
+	quarantine *Quarantine,
+) (*AviationStackClient, error) {
+	baseURL, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AviationStack base URL: %w", err)
+	}
+	if !strings.HasSuffix(baseURL.Path, "/") {
+		baseURL.Path += "/"
+	}
+
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+		httpClient = &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		}
+	}
+
+	httpClient, err = withRecording(httpClient, cfg.Mode, cfg.RecordingsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AviationStackClient{
+		baseURL:     baseURL,
+		accessKeys:  cfg.AccessKeys,
+		quota:       quota,
+		userAgent:   cfg.UserAgent,
+		httpClient:  httpClient,
+		requireKey:  cfg.Mode != ModeReplay,
+		pageSize:    cfg.PageSize,
+		concurrency: cfg.Concurrency,
+		cache:       cache,
+		quarantine:  quarantine,
+
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: cfg.RetryBaseDelay,
+		retryMaxDelay:  cfg.RetryMaxDelay,
+	}, nil
+}
+
+// requestURL builds the URL for endpoint, adding the access key and any
+// "key=value" query parameters.
+func (c *AviationStackClient) requestURL(accessKey, endpoint string, queryParams ...string) string {
+	parsedURL := *c.baseURL
+
+	// Set the endpoint path
+	parsedURL.Path += endpoint
+
+	query := parsedURL.Query()
+	query.Set("access_key", accessKey)
+
+	for _, param := range queryParams {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) == 2 {
+			query.Set(parts[0], parts[1])
+		}
+	}
+
+	parsedURL.RawQuery = query.Encode()
+
+	return parsedURL.String()
+}
+
+// open returns the body of endpoint for the caller to stream and close.
+// With a cache a fresh cached body is served without a request, and a
+// fetched body is read into memory and cached as a *cachedBody.
+func (c *AviationStackClient) open(ctx context.Context, endpoint string, queryParams ...string) (io.ReadCloser, error) {
+	if c.cache == nil {
+		return c.request(ctx, endpoint, queryParams...)
+	}
+
+	key := cacheKey(endpoint, queryParams)
+	if cached, ok := c.cache.get(ctx, key); ok {
+		return cached, nil
+	}
+
+	body, err := c.request(ctx, endpoint, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return c.cache.put(ctx, endpoint, key, data), nil
+}
+
+// request performs a GET request on endpoint. Rate limiting, 5xx responses
+// and transport errors are retried with jittered exponential backoff,
+// honouring Retry-After when it is sent. A key rejected for its quota or as
+// invalid is retried with the next one, without waiting; with a quota
+// tracker it is also taken out of rotation. When no key is left the
+// rejection is returned. Every retry counts against maxRetries. Once the
+// body is handed over nothing is retried, since part of it may already have
+// been consumed. Waiting between attempts stops when ctx is done.
+func (c *AviationStackClient) request(ctx context.Context, endpoint string, queryParams ...string) (io.ReadCloser, error) {
+	if c.requireKey && len(c.accessKeys) == 0 {
+		return nil, fmt.Errorf("missing API access key")
+	}
+
+	// the keys rejected during this request, and the last rejection
+	rejected := make(map[string]bool)
+	var rejection error
+	for attempt := 0; ; attempt++ {
+		accessKey, err := c.accessKey(ctx, rejected)
+		if rejection != nil && errors.Is(err, ErrQuotaExceeded) {
+			return nil, rejection
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := c.get(ctx, c.requestURL(accessKey, endpoint, queryParams...))
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		if accessKey != "" && (errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrInvalidAccessKey)) {
+			rejected[accessKey], rejection = true, err
+			if c.quota != nil {
+				if exhaustErr := c.quota.exhaust(ctx, accessKey); exhaustErr != nil {
+					return nil, fmt.Errorf("%w, and %w", err, exhaustErr)
+				}
+			}
+			if attempt >= c.maxRetries {
+				return nil, err
+			}
+			slog.Warn("AviationStack rejected the access key, trying the next one",
+				"endpoint", endpoint, "key", keyHint(accessKey), "error", err)
+			continue
+		}
+
+		var apiErr *APIError
+		isAPIError := errors.As(err, &apiErr)
+		if attempt >= c.maxRetries || (isAPIError && !apiErr.retryable()) {
+			return nil, err
+		}
+
+		delay := c.backoff(attempt)
+		if isAPIError && apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		slog.Warn("Retrying AviationStack request",
+			"endpoint", endpoint, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// accessKey returns the key for the next request, skipping the ones in
+// rejected. It returns ErrQuotaExceeded when no key is left.
+func (c *AviationStackClient) accessKey(ctx context.Context, rejected map[string]bool) (string, error) {
+	if len(c.accessKeys) == 0 {
+		return "", nil
+	}
+	if c.quota != nil {
+		return c.quota.acquire(ctx)
+	}
+	for range c.accessKeys {
+		key := c.accessKeys[int(c.nextKey.Add(1)-1)%len(c.accessKeys)]
+		if !rejected[key] {
+			return key, nil
+		}
+	}
+	return "", ErrQuotaExceeded
+}
+
+// Allow reports whether a job may spend API requests now, see
+// QuotaTracker.Allow. Without a quota tracker it always may.
+func (c *AviationStackClient) Allow(ctx context.Context, critical bool) error {
+	if c.quota == nil {
+		return nil
+	}
+	return c.quota.Allow(ctx, critical)
+}
+
+// get performs a single request. Unsuccessful responses are returned as *APIError.
+func (c *AviationStackClient) get(ctx context.Context, requestURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	countCall(ctx)
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make GET request: %w", err)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		return nil, newAPIError(response, body)
+	}
+
+	return response.Body, nil
+}
+
+// backoff returns a random delay in [0, min(retryMaxDelay, retryBaseDelay*2^attempt)).
+func (c *AviationStackClient) backoff(attempt int) time.Duration {
+	ceiling := c.retryMaxDelay
+	if attempt < 32 {
+		if d := c.retryBaseDelay << attempt; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
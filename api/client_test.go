@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/FACorreiaa/go-ollama/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for an httptest server running handler,
+// retrying twice without waiting.
+func newTestClient(t *testing.T, handler http.HandlerFunc, keys ...string) *AviationStackClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewAviationStackClient(&config.AviationStackConfig{
+		BaseURL:        server.URL + "/v1",
+		AccessKeys:     keys,
+		UserAgent:      "go-ollama-test",
+		PageSize:       100,
+		Concurrency:    4,
+		MaxRetries:     2,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+		Mode:           ModeLive,
+	}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// keyResponses answers each request with the next status and body queued
+// for its access key, or 200 once they run out, recording the keys used.
+type keyResponses struct {
+	mu        sync.Mutex
+	responses map[string][]response
+	keys      []string
+}
+
+type response struct {
+	status int
+	body   string
+}
+
+func (k *keyResponses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key := r.URL.Query().Get("access_key")
+	k.keys = append(k.keys, key)
+	queued := k.responses[key]
+	if len(queued) == 0 {
+		w.Write([]byte(`{"data":[]}`))
+		return
+	}
+	k.responses[key] = queued[1:]
+	w.WriteHeader(queued[0].status)
+	w.Write([]byte(queued[0].body))
+}
+
+const (
+	invalidKeyBody = `{"error":{"code":"invalid_access_key","message":"You have not supplied a valid API Access Key."}}`
+	usageLimitBody = `{"error":{"code":"usage_limit_reached","message":"Your monthly usage limit has been reached."}}`
+	rateLimitBody  = `{"error":{"code":"rate_limit_reached","message":"Too many requests."}}`
+	restrictedBody = `{"error":{"code":"function_access_restricted","message":"Not on your plan."}}`
+)
+
+func TestRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		responses map[string][]response
+		// wantErr is nil for a request that succeeds
+		wantErr  error
+		wantKeys []string
+	}{
+		{
+			name:     "success",
+			keys:     []string{"a"},
+			wantKeys: []string{"a"},
+		},
+		{
+			name:      "server errors are retried",
+			keys:      []string{"a"},
+			responses: map[string][]response{"a": {{500, ""}, {502, "<html>Bad Gateway</html>"}}},
+			wantKeys:  []string{"a", "a", "a"},
+		},
+		{
+			name:      "rate limit is retried",
+			keys:      []string{"a"},
+			responses: map[string][]response{"a": {{429, rateLimitBody}}},
+			wantKeys:  []string{"a", "a"},
+		},
+		{
+			name:      "retries are bounded by maxRetries",
+			keys:      []string{"a"},
+			responses: map[string][]response{"a": {{503, ""}, {503, ""}, {503, ""}, {503, ""}}},
+			wantErr:   &APIError{StatusCode: 503},
+			wantKeys:  []string{"a", "a", "a"},
+		},
+		{
+			name:      "restricted endpoint is not retried",
+			keys:      []string{"a"},
+			responses: map[string][]response{"a": {{403, restrictedBody}}},
+			wantErr:   ErrFunctionAccessRestricted,
+			wantKeys:  []string{"a"},
+		},
+		{
+			name:      "invalid key rotates to the next key",
+			keys:      []string{"a", "b"},
+			responses: map[string][]response{"a": {{401, invalidKeyBody}}},
+			wantKeys:  []string{"a", "b"},
+		},
+		{
+			name:      "spent key rotates to the next key",
+			keys:      []string{"a", "b"},
+			responses: map[string][]response{"a": {{429, usageLimitBody}}},
+			wantKeys:  []string{"a", "b"},
+		},
+		{
+			name:      "single invalid key reports the invalid key",
+			keys:      []string{"a"},
+			responses: map[string][]response{"a": {{401, invalidKeyBody}}},
+			wantErr:   ErrInvalidAccessKey,
+			wantKeys:  []string{"a"},
+		},
+		{
+			name: "every key spent",
+			keys: []string{"a", "b"},
+			responses: map[string][]response{
+				"a": {{429, usageLimitBody}},
+				"b": {{429, usageLimitBody}},
+			},
+			wantErr:  ErrQuotaExceeded,
+			wantKeys: []string{"a", "b"},
+		},
+		{
+			name: "rotation is bounded by maxRetries",
+			keys: []string{"a", "b", "c", "d"},
+			responses: map[string][]response{
+				"a": {{401, invalidKeyBody}},
+				"b": {{401, invalidKeyBody}},
+				"c": {{401, invalidKeyBody}},
+			},
+			wantErr:  ErrInvalidAccessKey,
+			wantKeys: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &keyResponses{responses: tt.responses}
+			if server.responses == nil {
+				server.responses = map[string][]response{}
+			}
+			client := newTestClient(t, server.ServeHTTP, tt.keys...)
+
+			body, err := client.request(context.Background(), "airports")
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(body)
+				body.Close()
+				if string(data) != `{"data":[]}` {
+					t.Errorf("got body %q", data)
+				}
+			case *APIError:
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != want.StatusCode {
+					t.Errorf("got error %v, want status %d", err, want.StatusCode)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("got error %v, want %v", err, want)
+				}
+			}
+
+			if len(server.keys) != len(tt.wantKeys) {
+				t.Fatalf("got requests with keys %q, want %q", server.keys, tt.wantKeys)
+			}
+			for i := range tt.wantKeys {
+				if server.keys[i] != tt.wantKeys[i] {
+					t.Errorf("got requests with keys %q, want %q", server.keys, tt.wantKeys)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	var got *http.Request
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"data":[]}`))
+	}, "secret")
+
+	body, err := client.request(context.Background(), "flights", "dep_iata=LIS", "offset=100", "malformed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if got.URL.Path != "/v1/flights" {
+		t.Errorf("got path %q, want /v1/flights", got.URL.Path)
+	}
+	query := got.URL.Query()
+	if query.Get("access_key") != "secret" || query.Get("dep_iata") != "LIS" || query.Get("offset") != "100" {
+		t.Errorf("got query %q", got.URL.RawQuery)
+	}
+	if query.Has("malformed") {
+		t.Errorf("got query %q, want parameters without a value dropped", got.URL.RawQuery)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "go-ollama-test" {
+		t.Errorf("got User-Agent %q", ua)
+	}
+}
+
+func TestRequestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}, "a")
+
+	if _, err := client.request(ctx, "airports"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want the request cancelled instead of waiting", err)
+	}
+}
+
+func TestRequestMissingKey(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("got a request without an access key")
+	})
+	if _, err := client.request(context.Background(), "airports"); err == nil {
+		t.Error("got no error without an access key")
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvSource parses one record per CSV line. A line that does not parse is
+// handed to reject, as a JSON array of its fields, and skipped; without
+// reject it fails the source.
+type csvSource[T any] struct {
+	path      string
+	reader    *csv.Reader
+	minFields int
+	parse     func([]string) (T, error)
+	reject    func(json.RawMessage, error)
+	current   T
+	accepted  int
+	err       error
+}
+
+func (s *csvSource[T]) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		fields, err := s.reader.Read()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			s.err = fmt.Errorf("error reading %s: %w", s.path, err)
+			return false
+		}
+
+		line, _ := s.reader.FieldPos(0)
+		var record T
+		if len(fields) < s.minFields {
+			err = fmt.Errorf("%s:%d: expected %d fields, got %d", s.path, line, s.minFields, len(fields))
+		} else if record, err = s.parse(fields); err != nil {
+			err = fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		if err != nil {
+			if s.reject == nil {
+				s.err = err
+				return false
+			}
+			raw, _ := json.Marshal(fields)
+			s.reject(raw, err)
+			continue
+		}
+
+		s.current = record
+		s.accepted++
+		return true
+	}
+}
+
+func (s *csvSource[T]) Record() T {
+	return s.current
+}
+
+func (s *csvSource[T]) Err() error {
+	return s.err
+}
+
+func newCSVSource[T any](
+	path string,
+	reader *csv.Reader,
+	minFields int,
+	parse func([]string) (T, error),
+	reject func(json.RawMessage, error),
+) *csvSource[T] {
+	return &csvSource[T]{path: path, reader: reader, minFields: minFields, parse: parse, reject: reject}
+}
+
+func csvReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	return reader
+}
+
+// csvRow gives access to the fields of a CSV line by header name.
+type csvRow struct {
+	fields []string
+	index  map[string]int
+}
+
+func (r csvRow) str(name string) string {
+	return strings.TrimSpace(r.fields[r.index[name]])
+}
+
+// intValue rejects an empty field, use intPtr for optional ones.
+func (r csvRow) intValue(name string) (int, error) {
+	value := r.str(name)
+	if value == "" {
+		return 0, fmt.Errorf("missing %s", name)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// intPtr returns nil for an empty field so it is stored as NULL.
+func (r csvRow) intPtr(name string) (*int, error) {
+	if r.str(name) == "" {
+		return nil, nil
+	}
+	n, err := r.intValue(name)
+	return &n, err
+}
+
+func (r csvRow) floatPtr(name string) (*float64, error) {
+	value := r.str(name)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &f, nil
+}
+
+// readHeaderCSV hands fn a single source over a CSV file whose first line
+// names the columns. Every name in columns must be present. Lines that do
+// not parse are quarantined.
+func readHeaderCSV[T any](
+	ctx context.Context,
+	quarantine *Quarantine,
+	path string,
+	columns []string,
+	parse func(csvRow) (T, error),
+	fn func(context.Context, RecordSource[T]) error,
+) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	reader := csvReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading %s header: %w", path, err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, name := range columns {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("%s: missing column %q", path, name)
+		}
+	}
+
+	run := quarantine.run(filepath.Base(path))
+	src := newCSVSource(path, reader, len(header), func(fields []string) (T, error) {
+		return parse(csvRow{fields: fields, index: index})
+	}, run.reject())
+	if err := fn(ctx, src); err != nil {
+		return err
+	}
+	run.accept(src.accepted)
+	return run.finish(ctx)
+}
+
+// atoi treats empty fields as 0.
+func atoi(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
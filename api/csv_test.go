@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type csvRecord struct {
+	ID   int
+	Name string
+}
+
+func parseCSVRecord(fields []string) (csvRecord, error) {
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return csvRecord{}, err
+	}
+	return csvRecord{ID: id, Name: fields[1]}, nil
+}
+
+// rejected collects what a source hands to reject.
+type rejected struct {
+	raws []string
+	errs []error
+}
+
+func (r *rejected) reject(raw json.RawMessage, err error) {
+	r.raws = append(r.raws, string(raw))
+	r.errs = append(r.errs, err)
+}
+
+func TestCSVSource(t *testing.T) {
+	const data = "1,Lisbon\n" +
+		"2,\"Porto, Norte\"\n" +
+		"x,Faro\n" +
+		"3\n" +
+		"4,\"Funchal\"\n"
+
+	var got rejected
+	src := newCSVSource("cities.dat", csvReader(strings.NewReader(data)), 2, parseCSVRecord, got.reject)
+
+	var records []csvRecord
+	for src.Next() {
+		records = append(records, src.Record())
+	}
+	if err := src.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []csvRecord{{1, "Lisbon"}, {2, "Porto, Norte"}, {4, "Funchal"}}
+	if len(records) != len(want) {
+		t.Fatalf("got records %v, want %v", records, want)
+	}
+	for i := range want {
+		if records[i] != want[i] {
+			t.Errorf("got record %v, want %v", records[i], want[i])
+		}
+	}
+	if src.accepted != len(want) {
+		t.Errorf("got %d accepted, want %d", src.accepted, len(want))
+	}
+
+	wantRaws := []string{`["x","Faro"]`, `["3"]`}
+	if len(got.raws) != len(wantRaws) {
+		t.Fatalf("got rejected %q, want %q", got.raws, wantRaws)
+	}
+	for i := range wantRaws {
+		if got.raws[i] != wantRaws[i] {
+			t.Errorf("got rejected %q, want %q", got.raws[i], wantRaws[i])
+		}
+	}
+	if !strings.HasPrefix(got.errs[0].Error(), "cities.dat:3: ") {
+		t.Errorf("got error %q, want it to name the file and line", got.errs[0])
+	}
+	if !strings.Contains(got.errs[1].Error(), "expected 2 fields, got 1") {
+		t.Errorf("got error %q, want the missing fields reported", got.errs[1])
+	}
+}
+
+func TestCSVSourceWithoutReject(t *testing.T) {
+	src := newCSVSource("cities.dat", csvReader(strings.NewReader("1,Lisbon\nx,Faro\n5,Braga\n")), 2, parseCSVRecord, nil)
+
+	count := 0
+	for src.Next() {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("got %d records, want the source to stop at the malformed line", count)
+	}
+	var numErr *strconv.NumError
+	if err := src.Err(); !errors.As(err, &numErr) || !strings.HasPrefix(err.Error(), "cities.dat:2: ") {
+		t.Errorf("got error %v, want the parse error of line 2", err)
+	}
+}
+
+func TestCSVRow(t *testing.T) {
+	row := csvRow{
+		fields: []string{" 42 ", "", "38.7", "abc"},
+		index:  map[string]int{"id": 0, "empty": 1, "latitude": 2, "bad": 3},
+	}
+
+	if n, err := row.intValue("id"); err != nil || n != 42 {
+		t.Errorf("got intValue %d, %v, want 42", n, err)
+	}
+	if p, err := row.intPtr("empty"); err != nil || p != nil {
+		t.Errorf("got intPtr %v, %v, want nil for an empty field", p, err)
+	}
+	if p, err := row.floatPtr("latitude"); err != nil || p == nil || *p != 38.7 {
+		t.Errorf("got floatPtr %v, %v, want 38.7", p, err)
+	}
+	if p, err := row.floatPtr("empty"); err != nil || p != nil {
+		t.Errorf("got floatPtr %v, %v, want nil for an empty field", p, err)
+	}
+	if _, err := row.intValue("empty"); err == nil || err.Error() != "missing empty" {
+		t.Errorf("got error %v, want an empty field rejected", err)
+	}
+	if _, err := row.intValue("bad"); err == nil || !strings.HasPrefix(err.Error(), "invalid bad: ") {
+		t.Errorf("got error %v, want the column named", err)
+	}
+}
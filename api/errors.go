@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrQuotaExceeded            = errors.New("aviationstack: monthly usage limit reached")
+	ErrInvalidAccessKey         = errors.New("aviationstack: invalid or missing access key")
+	ErrRateLimited              = errors.New("aviationstack: rate limit reached")
+	ErrFunctionAccessRestricted = errors.New("aviationstack: endpoint not available on the current plan")
+)
+
+// APIError is the {"error":{"code","message"}} envelope AviationStack sends
+// alongside any status >= 400. It unwraps to one of the Err* sentinels when
+// the code is known, so callers can use errors.Is.
+type APIError struct {
+	StatusCode int           `json:"-"`
+	Code       string        `json:"code"`
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("aviationstack: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("aviationstack: %s (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case "usage_limit_reached":
+		return ErrQuotaExceeded
+	case "invalid_access_key", "missing_access_key", "inactive_user":
+		return ErrInvalidAccessKey
+	case "rate_limit_reached":
+		return ErrRateLimited
+	case "function_access_restricted", "https_access_restricted":
+		return ErrFunctionAccessRestricted
+	}
+
+	if e.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// retryable reports whether repeating the request may succeed. A spent
+// monthly quota also comes back as 429 but will not recover by waiting.
+func (e *APIError) retryable() bool {
+	if errors.Is(e, ErrQuotaExceeded) {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// newAPIError decodes the error envelope from an unsuccessful response.
+func newAPIError(response *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: response.StatusCode}
+
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	envelope.Error = apiErr
+	// A non-JSON body (e.g. from a proxy) still yields a status-only error
+	_ = json.Unmarshal(body, &envelope)
+
+	apiErr.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+	return apiErr
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		wantCode   string
+		wantErr    error
+		retryable  bool
+		wantAfter  time.Duration
+	}{
+		{
+			name:     "usage limit",
+			status:   http.StatusTooManyRequests,
+			body:     `{"error":{"code":"usage_limit_reached","message":"Your monthly usage limit has been reached."}}`,
+			wantCode: "usage_limit_reached",
+			wantErr:  ErrQuotaExceeded,
+		},
+		{
+			name:       "rate limit with seconds",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "30",
+			body:       `{"error":{"code":"rate_limit_reached","message":"Too many requests."}}`,
+			wantCode:   "rate_limit_reached",
+			wantErr:    ErrRateLimited,
+			retryable:  true,
+			wantAfter:  30 * time.Second,
+		},
+		{
+			name:     "invalid key",
+			status:   http.StatusUnauthorized,
+			body:     `{"error":{"code":"invalid_access_key","message":"You have not supplied a valid API Access Key."}}`,
+			wantCode: "invalid_access_key",
+			wantErr:  ErrInvalidAccessKey,
+		},
+		{
+			name:     "restricted endpoint",
+			status:   http.StatusForbidden,
+			body:     `{"error":{"code":"function_access_restricted","message":"Not on your plan."}}`,
+			wantCode: "function_access_restricted",
+			wantErr:  ErrFunctionAccessRestricted,
+		},
+		{
+			name:      "bare 429",
+			status:    http.StatusTooManyRequests,
+			body:      `Too Many Requests`,
+			wantErr:   ErrRateLimited,
+			retryable: true,
+		},
+		{
+			name:      "proxy error page",
+			status:    http.StatusBadGateway,
+			body:      `<html>Bad Gateway</html>`,
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				response.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			err := newAPIError(response, []byte(tt.body))
+			if err.StatusCode != tt.status || err.Code != tt.wantCode {
+				t.Errorf("got status %d code %q, want %d %q", err.StatusCode, err.Code, tt.status, tt.wantCode)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want it to wrap %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err.Unwrap() != nil {
+				t.Errorf("got %v wrapping %v, want no sentinel", err, err.Unwrap())
+			}
+			if err.retryable() != tt.retryable {
+				t.Errorf("got retryable %v, want %v", err.retryable(), tt.retryable)
+			}
+			if err.RetryAfter != tt.wantAfter {
+				t.Errorf("got retry after %s, want %s", err.RetryAfter, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "120", min: 2 * time.Minute, max: 2 * time.Minute},
+		{value: "0", min: 0, max: 0},
+		{value: "-5", min: 0, max: 0},
+		{value: "soon", min: 0, max: 0},
+		{value: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), min: 59 * time.Minute, max: time.Hour},
+		{value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// flightKeyColumns identify one actual flight in the flights table.
+var flightKeyColumns = []string{"flight_iata", "flight_date", "departure_iata"}
+
+// upsertFlights inserts the flights staged in staging and updates known ones
+// in place. Flights without an IATA flight number or date cannot be told
+// apart and are skipped; of records repeating a flight the last one wins.
+// The live_* columns keep their stored position, which may come from a
+// receiver, when AviationStack sends none.
+func upsertFlights(ctx context.Context, tx pgx.Tx, staging string) (SyncResult, error) {
+	var result SyncResult
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(
+		`DELETE FROM %s WHERE coalesce(flight_iata, '') = '' OR coalesce(flight_date, '') = ''`, staging,
+	))
+	if err != nil {
+		return result, fmt.Errorf("error skipping flights without a key: %w", err)
+	}
+	if skipped := tag.RowsAffected(); skipped > 0 {
+		slog.Warn("Skipped flights without a flight number or date", "count", skipped)
+	}
+	staged, err := dedupeStaged(ctx, tx, staging, flightKeyColumns)
+	if err != nil {
+		return result, err
+	}
+
+	events, err := recordFlightEvents(ctx, tx, staging)
+	if err != nil {
+		return result, err
+	}
+	result.Events = events
+
+	var set, current, incoming []string
+	for _, column := range flightColumns {
+		if column == "id" || column == "created_at" || slices.Contains(flightKeyColumns, column) {
+			continue
+		}
+		value := "excluded." + column
+		if strings.HasPrefix(column, "live_") {
+			value = fmt.Sprintf(
+				"CASE WHEN excluded.live_updated <> '' THEN excluded.%[1]s ELSE flights.%[1]s END", column,
+			)
+		}
+		set = append(set, fmt.Sprintf("%s = %s", column, value))
+		current = append(current, "flights."+column)
+		incoming = append(incoming, value)
+	}
+	list := strings.Join(flightColumns, ", ")
+
+	// xmax is 0 for a row inserted by this statement. Inserting in key order
+	// keeps concurrent upserts from deadlocking.
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		WITH upserted AS (
+			INSERT INTO flights (%[1]s)
+			SELECT %[1]s FROM %[2]s ORDER BY %[3]s
+			ON CONFLICT (%[3]s) DO UPDATE
+			SET %[4]s, updated_at = now()
+			WHERE (%[5]s) IS DISTINCT FROM (%[6]s)
+			RETURNING xmax = 0 AS inserted
+		)
+		SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted`,
+		list, staging, strings.Join(flightKeyColumns, ", "),
+		strings.Join(set, ", "), strings.Join(current, ", "), strings.Join(incoming, ", "),
+	)).Scan(&result.Inserted, &result.Updated); err != nil {
+		return result, fmt.Errorf("error upserting into flights table: %w", err)
+	}
+	result.Unchanged = staged - result.Inserted - result.Updated
+	return result, nil
+}
+
+// flightEventFields are the columns whose changes are recorded in
+// flight_event, with the event type of each.
+var flightEventFields = []struct {
+	event  structs.FlightEventType
+	column string
+}{
+	{structs.StatusChanged, "flight_status"},
+	{structs.DelayChanged, "departure_delay"},
+	{structs.DelayChanged, "arrival_delay"},
+	{structs.GateChanged, "departure_gate"},
+	{structs.GateChanged, "arrival_gate"},
+	{structs.TerminalChanged, "departure_terminal"},
+	{structs.TerminalChanged, "arrival_terminal"},
+	{structs.RunwayTimeSet, "departure_estimated_runway"},
+	{structs.RunwayTimeSet, "departure_actual_runway"},
+	{structs.RunwayTimeSet, "arrival_estimated_runway"},
+	{structs.RunwayTimeSet, "arrival_actual_runway"},
+	{structs.Diversion, "arrival_iata"},
+}
+
+// recordFlightEvents compares the staged flights with the stored ones,
+// before they are updated, and adds a flight_event for every tracked column
+// that changed. Empty strings count as unset, and a diversion needs a
+// previous arrival airport. New flights have no events.
+func recordFlightEvents(ctx context.Context, tx pgx.Tx, staging string) (int, error) {
+	values := make([]string, len(flightEventFields))
+	for i, field := range flightEventFields {
+		values[i] = fmt.Sprintf(
+			"('%[1]s', '%[2]s', nullif(f.%[2]s::text, ''), nullif(s.%[2]s::text, ''))",
+			field.event, field.column,
+		)
+	}
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO flight_event (flight_id, event_type, field, old_value, new_value)
+		SELECT f.id, e.event_type, e.field, e.old_value, e.new_value
+		FROM %[1]s s
+		JOIN flights f ON (f.%[2]s) = (s.%[3]s)
+		CROSS JOIN LATERAL (VALUES %[4]s) AS e(event_type, field, old_value, new_value)
+		WHERE e.old_value IS DISTINCT FROM e.new_value
+		AND (e.event_type <> '%[5]s' OR e.old_value IS NOT NULL)`,
+		staging,
+		strings.Join(flightKeyColumns, ", f."),
+		strings.Join(flightKeyColumns, ", s."),
+		strings.Join(values, ",\n\t\t\t"),
+		structs.Diversion,
+	))
+	if err != nil {
+		return 0, fmt.Errorf("error recording flight events: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// pollFlights upserts the current flights matching query, "key=value"
+// AviationStack parameters such as "dep_iata=LIS". It is skipped when the
+// cached response has not changed since the last poll.
+func pollFlights(
+	ctx context.Context,
+	conn *pgxpool.Pool,
+	client *AviationStackClient,
+	query []string,
+) (SyncResult, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("error starting flights sync: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	flights, err := stageChanged(ctx, tx, client, "flights", "flights", flightColumns, flightRow, query...)
+	if errors.Is(err, ErrNotModified) {
+		slog.Info("Response unchanged since the last sync, skipping", "table", "flights")
+		return SyncResult{}, nil
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	result, err := upsertFlights(ctx, tx, flights.table)
+	if err != nil {
+		return result, err
+	}
+	result.Rejected = flights.rejected
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("error committing flights sync: %w", err)
+	}
+
+	slog.Info("Synced table", "table", "flights",
+		"inserted", result.Inserted, "updated", result.Updated, "unchanged", result.Unchanged,
+		"rejected", result.Rejected, "events", result.Events)
+	flights.commit()
+	return result, nil
+}
+
+// FlightRepository reads flights and their events.
+type FlightRepository struct {
+	conn *pgxpool.Pool
+}
+
+func NewFlightRepository(conn *pgxpool.Pool) *FlightRepository {
+	return &FlightRepository{conn: conn}
+}
+
+// FlightTimeline returns the flight with the given key and its events. It
+// returns pgx.ErrNoRows when there is no such flight.
+func (r *FlightRepository) FlightTimeline(
+	ctx context.Context,
+	flightIata, flightDate, departureIata string,
+) (*structs.FlightTimeline, error) {
+	timeline := structs.FlightTimeline{Events: []structs.FlightEvent{}}
+	if err := r.conn.QueryRow(ctx, `
+		select id::text, flight_iata, flight_date, departure_iata, coalesce(arrival_iata, ''),
+			coalesce(flight_status, '')
+		from flights where flight_iata = $1 and flight_date = $2 and departure_iata = $3
+		`, flightIata, flightDate, departureIata,
+	).Scan(
+		&timeline.ID, &timeline.FlightIata, &timeline.FlightDate, &timeline.DepartureIata,
+		&timeline.ArrivalIata, &timeline.FlightStatus,
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn.Query(ctx, `
+		select event_type, field, old_value, new_value, created_at
+		from flight_event where flight_id = $1
+		order by created_at, field
+		`, timeline.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (structs.FlightEvent, error) {
+		var event structs.FlightEvent
+		err := row.Scan(&event.Type, &event.Field, &event.OldValue, &event.NewValue, &event.At)
+		return event, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		timeline.Events = events
+	}
+	return &timeline, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"github.com/FACorreiaa/go-ollama/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"testing"
+)
+
+// testPool connects to the database at TEST_DATABASE_URL and migrates it,
+// skipping the test when the variable is unset. Tests write to the tables,
+// so point it at a throwaway database.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	pool, err := db.Init(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if err := db.Migrate(pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// testRedis connects to the Redis at TEST_REDIS_URL, such as
+// redis://localhost:6379/15, skipping the test when the variable is unset.
+// The selected database is flushed before and after the test.
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opts)
+	ctx := context.Background()
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+	})
+	return client
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// What started a job run.
+const (
+	TriggerCron    = "cron"
+	TriggerManual  = "manual"
+	TriggerStartup = "startup"
+	TriggerRetry   = "retry"
+)
+
+// Status of a job run.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunDeferred  = "deferred"
+)
+
+// ErrJobDeferred is wrapped by the error of a job that chose not to run,
+// e.g. to save the API quota. Its run is recorded as deferred, not failed.
+var ErrJobDeferred = errors.New("job deferred")
+
+// JobRun is one run of a job as recorded in the job_run table.
+type JobRun struct {
+	ID            string     `json:"id"`
+	Job           string     `json:"job"`
+	Trigger       string     `json:"trigger"`
+	Status        string     `json:"status"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Inserted      int        `json:"inserted"`
+	Updated       int        `json:"updated"`
+	Unchanged     int        `json:"unchanged"`
+	Deleted       int        `json:"deleted"`
+	Rejected      int        `json:"rejected"`
+	UpstreamCalls int        `json:"upstream_calls"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// Duration is how long a finished run took, 0 while it is running.
+func (r JobRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+}
+
+// JobLedger records job runs in the job_run table.
+type JobLedger struct {
+	conn *pgxpool.Pool
+}
+
+func NewJobLedger(conn *pgxpool.Pool) *JobLedger {
+	return &JobLedger{conn: conn}
+}
+
+// ledgerTimeout bounds writing a run, which happens after the job's own
+// context may have expired.
+const ledgerTimeout = 5 * time.Second
+
+// Run runs fn as job and records the run with the rows it changed, the
+// records it quarantined, the AviationStack requests it made and its error. A failure to record the run
+// is logged and does not fail the job. It returns the error of fn.
+func (l *JobLedger) Run(
+	ctx context.Context,
+	job, trigger string,
+	fn func(context.Context) (SyncResult, error),
+) error {
+	ctx, calls := withCallCounter(ctx)
+	startTime := time.Now()
+
+	var id string
+	if err := l.conn.QueryRow(ctx, `
+		insert into job_run (job, trigger, status, started_at) values ($1, $2, $3, $4) returning id::text`,
+		job, trigger, RunRunning, startTime,
+	).Scan(&id); err != nil {
+		handleError(err, "Error recording job run")
+	}
+
+	result, err := fn(ctx)
+
+	status := RunSucceeded
+	var message *string
+	if err != nil {
+		status = RunFailed
+		if errors.Is(err, ErrJobDeferred) {
+			status = RunDeferred
+		}
+		text := err.Error()
+		message = &text
+	}
+
+	logger := slog.Info
+	if status == RunFailed {
+		logger = slog.Error
+	}
+	logger("Job finished", "job", job, "trigger", trigger, "status", status,
+		"duration", time.Since(startTime), "upstream_calls", calls.Load(), "error", err)
+
+	if id == "" {
+		return err
+	}
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ledgerTimeout)
+	defer cancel()
+	if _, dbErr := l.conn.Exec(writeCtx, `
+		update job_run
+		set status = $2, finished_at = now(), inserted = $3, updated = $4, unchanged = $5,
+			deleted = $6, rejected = $7, upstream_calls = $8, error = $9
+		where id = $1`,
+		id, status, result.Inserted, result.Updated, result.Unchanged,
+		result.Deleted, result.Rejected, calls.Load(), message,
+	); dbErr != nil {
+		handleError(dbErr, "Error recording job run")
+	}
+	return err
+}
+
+// Recent returns the latest runs, newest first. A non-empty status only
+// returns runs with that status.
+func (l *JobLedger) Recent(ctx context.Context, status string, limit int) ([]JobRun, error) {
+	rows, err := l.conn.Query(ctx, `
+		select id::text, job, trigger, status, started_at, finished_at, inserted, updated,
+			unchanged, deleted, rejected, upstream_calls, coalesce(error, '')
+		from job_run
+		where $1 = '' or status = $1
+		order by started_at desc
+		limit $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (JobRun, error) {
+		var run JobRun
+		err := row.Scan(
+			&run.ID, &run.Job, &run.Trigger, &run.Status, &run.StartedAt, &run.FinishedAt,
+			&run.Inserted, &run.Updated, &run.Unchanged, &run.Deleted, &run.Rejected, &run.UpstreamCalls,
+			&run.Error,
+		)
+		return run, err
+	})
+}
+
+type callCounterKey struct{}
+
+// withCallCounter returns a context counting the AviationStack requests made
+// with it.
+func withCallCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	calls := new(atomic.Int64)
+	return context.WithValue(ctx, callCounterKey{}, calls), calls
+}
+
+func countCall(ctx context.Context) {
+	if calls, ok := ctx.Value(callCounterKey{}).(*atomic.Int64); ok {
+		calls.Add(1)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
+)
+
+// ErrLockHeld is returned by JobLocker.Do when another instance holds the
+// lock.
+var ErrLockHeld = errors.New("lock held by another instance")
+
+const (
+	jobLockKeyPrefix = "jobs:lock:"
+	jobSlotKeyPrefix = "jobs:slot:"
+)
+
+// Only the holder, identified by its token, may extend or release a lock.
+var (
+	renewLock = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLock = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+)
+
+// JobLocker makes sure only one instance runs a job at a time. A lock is a
+// Redis key set with SET NX and a lease of ttl, renewed while the job runs.
+// If the holder dies its lease runs out and the next run of the job, on any
+// instance, takes the lock. Scheduled runs also claim their schedule slot,
+// so a job runs once per slot even when the instances fire it at different
+// times.
+type JobLocker struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewJobLocker(redisClient *redis.Client, ttl time.Duration) *JobLocker {
+	return &JobLocker{redis: redisClient, ttl: ttl}
+}
+
+// Do runs fn holding the lock for name, or returns ErrLockHeld right away
+// when another instance holds it. The context given to fn is cancelled if
+// the lease is lost. A nil JobLocker runs fn without a lock.
+func (l *JobLocker) Do(ctx context.Context, name string, fn func(context.Context) error) error {
+	if l == nil {
+		return fn(ctx)
+	}
+
+	key := jobLockKeyPrefix + name
+	token := uuid.NewString()
+	ok, err := l.redis.SetNX(ctx, key, token, l.ttl).Result()
+	if err != nil {
+		return fmt.Errorf("error taking lock %s: %w", name, err)
+	}
+	if !ok {
+		return ErrLockHeld
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		l.renew(ctx, key, token, cancel)
+	}()
+
+	err = fn(ctx)
+	cancel(nil)
+	<-renewed
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), ledgerTimeout)
+	defer cancelRelease()
+	if err := releaseLock.Run(releaseCtx, l.redis, []string{key}, token).Err(); err != nil {
+		slog.Warn("Error releasing job lock", "job", name, "error", err)
+	}
+	return err
+}
+
+// Claim takes the schedule slot of job name starting at slot for this
+// instance, for hold, usually the interval of the schedule. It reports
+// false when another instance already claimed the slot. A nil JobLocker
+// claims every slot.
+func (l *JobLocker) Claim(ctx context.Context, name string, slot time.Time, hold time.Duration) (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+
+	key := fmt.Sprintf("%s%s:%d", jobSlotKeyPrefix, name, slot.Unix())
+	ok, err := l.redis.SetNX(ctx, key, uuid.NewString(), hold).Result()
+	if err != nil {
+		return false, fmt.Errorf("error claiming slot of %s: %w", name, err)
+	}
+	return ok, nil
+}
+
+// renew extends the lease every third of its ttl until ctx is done. It
+// cancels ctx once another instance took the lock, or when renewing failed
+// for long enough that the lease may have run out.
+func (l *JobLocker) renew(ctx context.Context, key, token string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			extended, err := renewLock.Run(ctx, l.redis, []string{key}, token, l.ttl.Milliseconds()).Int()
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err == nil && extended == 0:
+				cancel(fmt.Errorf("lost lock %s to another instance", key))
+				return
+			case err == nil:
+				renewedAt = time.Now()
+			case time.Since(renewedAt) >= l.ttl:
+				cancel(fmt.Errorf("lost lock %s: %w", key, err))
+				return
+			default:
+				slog.Warn("Error renewing job lock", "key", key, "error", err)
+			}
+		}
+	}
+}
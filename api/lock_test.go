@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobLockerDo(t *testing.T) {
+	locker := NewJobLocker(testRedis(t), time.Second)
+	ctx := context.Background()
+	key := jobLockKeyPrefix + "city"
+
+	err := locker.Do(ctx, "city", func(ctx context.Context) error {
+		if err := locker.Do(ctx, "city", func(context.Context) error { return nil }); !errors.Is(err, ErrLockHeld) {
+			t.Errorf("got error %v, want ErrLockHeld while the lock is held", err)
+		}
+		if err := locker.Do(ctx, "country", func(context.Context) error { return nil }); err != nil {
+			t.Errorf("got error %v, want another job's lock free", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := locker.redis.Exists(ctx, key).Val(); n != 0 {
+		t.Error("got the lock kept after Do returned")
+	}
+
+	want := errors.New("sync failed")
+	if err := locker.Do(ctx, "city", func(context.Context) error { return want }); !errors.Is(err, want) {
+		t.Errorf("got error %v, want the error of fn", err)
+	}
+	if n := locker.redis.Exists(ctx, key).Val(); n != 0 {
+		t.Error("got the lock kept after fn failed")
+	}
+}
+
+func TestJobLockerNil(t *testing.T) {
+	var locker *JobLocker
+	ran := false
+	if err := locker.Do(context.Background(), "city", func(context.Context) error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("got ran %t and error %v, want fn run without a lock", ran, err)
+	}
+	if ok, err := locker.Claim(context.Background(), "city", time.Now(), time.Minute); !ok || err != nil {
+		t.Errorf("got claimed %t and error %v, want every slot claimed", ok, err)
+	}
+}
+
+func TestJobLockerRenewal(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	locker := NewJobLocker(testRedis(t), ttl)
+	ctx := context.Background()
+	key := jobLockKeyPrefix + "city"
+
+	var token string
+	err := locker.Do(ctx, "city", func(ctx context.Context) error {
+		token = locker.redis.Get(ctx, key).Val()
+		// outlive the lease a few times over
+		select {
+		case <-ctx.Done():
+			t.Errorf("got the lock lost while renewing: %v", context.Cause(ctx))
+		case <-time.After(3 * ttl):
+		}
+		if n := locker.redis.Exists(ctx, key).Val(); n != 1 {
+			t.Error("got the lease expired while fn runs")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with the renewal stopped, a lease under the same token runs out
+	if err := locker.redis.Set(ctx, key, token, ttl).Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * ttl)
+	if n := locker.redis.Exists(ctx, key).Val(); n != 0 {
+		t.Error("got the lease renewed after Do returned")
+	}
+}
+
+func TestJobLockerLost(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	locker := NewJobLocker(testRedis(t), ttl)
+	ctx := context.Background()
+	key := jobLockKeyPrefix + "city"
+
+	err := locker.Do(ctx, "city", func(ctx context.Context) error {
+		// another instance took the lock after the lease ran out
+		if err := locker.redis.Set(ctx, key, "other", time.Minute).Err(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-ctx.Done():
+			if cause := context.Cause(ctx); cause == nil || !strings.Contains(cause.Error(), "another instance") {
+				t.Errorf("got cause %v, want the lock lost to another instance", cause)
+			}
+		case <-time.After(3 * ttl):
+			t.Error("got fn left running after the lock was lost")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the release leaves the other holder's lock alone
+	if got := locker.redis.Get(ctx, key).Val(); got != "other" {
+		t.Errorf("got lock %q, want the other holder's kept", got)
+	}
+}
+
+func TestJobLockerClaim(t *testing.T) {
+	locker := NewJobLocker(testRedis(t), time.Second)
+	ctx := context.Background()
+	slot := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	if ok, err := locker.Claim(ctx, "city", slot, time.Minute); !ok || err != nil {
+		t.Fatalf("got claimed %t and error %v, want the first claim to win", ok, err)
+	}
+	if ok, _ := locker.Claim(ctx, "city", slot, time.Minute); ok {
+		t.Error("got a slot claimed twice")
+	}
+	if ok, _ := locker.Claim(ctx, "city", slot.Add(time.Hour), time.Minute); !ok {
+		t.Error("got the next slot taken")
+	}
+	if ok, _ := locker.Claim(ctx, "country", slot, time.Minute); !ok {
+		t.Error("got another job's slot taken")
+	}
+}
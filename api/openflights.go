@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OpenFlightsProvider imports the OpenFlights data files (airports.dat,
+// airlines.dat, planes.dat and routes.dat) from a directory on disk, see
+// https://openflights.org/data. It has no countries, cities, taxes or
+// individual airplanes. Lines that do not parse are quarantined.
+type OpenFlightsProvider struct {
+	dir        string
+	quarantine *Quarantine
+}
+
+func NewOpenFlightsProvider(dir string, quarantine *Quarantine) *OpenFlightsProvider {
+	return &OpenFlightsProvider{dir: dir, quarantine: quarantine}
+}
+
+func (p *OpenFlightsProvider) Name() string {
+	return "openflights"
+}
+
+// Airline ID, Name, Alias, IATA, ICAO, Callsign, Country, Active
+func (p *OpenFlightsProvider) Airlines(ctx context.Context, fn func(context.Context, RecordSource[structs.Airline]) error) error {
+	return readCSV(ctx, p.quarantine, filepath.Join(p.dir, "airlines.dat"), 8, func(fields []string) (structs.Airline, error) {
+		id, err := atoi(fields[0])
+		if err != nil {
+			return structs.Airline{}, fmt.Errorf("invalid airline id: %w", err)
+		}
+
+		status := "inactive"
+		if fields[7] == "Y" {
+			status = "active"
+		}
+
+		return structs.Airline{
+			AirlineId:   id,
+			AirlineName: fields[1],
+			IataCode:    fields[3],
+			IcaoCode:    fields[4],
+			Callsign:    fields[5],
+			CountryName: fields[6],
+			Status:      status,
+		}, nil
+	}, fn)
+}
+
+// Name, IATA code, ICAO code. The file has no identifier, so plane_type_id
+// is derived from the codes, see planeTypeID.
+func (p *OpenFlightsProvider) Aircraft(ctx context.Context, fn func(context.Context, RecordSource[structs.Aircraft]) error) error {
+	return readCSV(ctx, p.quarantine, filepath.Join(p.dir, "planes.dat"), 3, func(fields []string) (structs.Aircraft, error) {
+		id, err := planeTypeID(fields[1], fields[2])
+		if err != nil {
+			return structs.Aircraft{}, err
+		}
+		return structs.Aircraft{
+			AircraftName: fields[0],
+			IataCode:     fields[1],
+			PlaneTypeId:  id,
+		}, nil
+	}, fn)
+}
+
+// planeTypeID derives a plane_type_id from the ICAO type designator, or the
+// IATA code when there is none. Each code is read as a bijective base-37
+// number, so distinct codes give distinct ids, and the id is negative so it
+// cannot collide with the AviationStack ids.
+func planeTypeID(iata, icao string) (int, error) {
+	code, kind := strings.ToUpper(icao), 0
+	if code == "" {
+		code, kind = strings.ToUpper(iata), 1
+	}
+	if code == "" {
+		return 0, errors.New("no IATA or ICAO code")
+	}
+	if len(code) > 4 {
+		return 0, fmt.Errorf("invalid type code %q", code)
+	}
+
+	n := 0
+	for _, c := range code {
+		var digit int
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int(c-'0') + 1
+		case c >= 'A' && c <= 'Z':
+			digit = int(c-'A') + 11
+		default:
+			return 0, fmt.Errorf("invalid type code %q", code)
+		}
+		n = n*37 + digit
+	}
+	return -(n*2 + kind), nil
+}
+
+func (p *OpenFlightsProvider) Airplanes(_ context.Context, _ func(context.Context, RecordSource[structs.Airplane]) error) error {
+	return ErrDatasetUnsupported
+}
+
+// Airport ID, Name, City, Country, IATA, ICAO, Latitude, Longitude, Altitude,
+// Timezone, DST, Tz database timezone, Type, Source
+func (p *OpenFlightsProvider) Airports(ctx context.Context, fn func(context.Context, RecordSource[structs.Airport]) error) error {
+	return readCSV(ctx, p.quarantine, filepath.Join(p.dir, "airports.dat"), 12, func(fields []string) (structs.Airport, error) {
+		id, err := atoi(fields[0])
+		if err != nil {
+			return structs.Airport{}, fmt.Errorf("invalid airport id: %w", err)
+		}
+		latitude, err := strconv.ParseFloat(fields[6], 64)
+		if err != nil {
+			return structs.Airport{}, fmt.Errorf("invalid latitude: %w", err)
+		}
+		longitude, err := strconv.ParseFloat(fields[7], 64)
+		if err != nil {
+			return structs.Airport{}, fmt.Errorf("invalid longitude: %w", err)
+		}
+
+		return structs.Airport{
+			AirportId:   id,
+			AirportName: fields[1],
+			CountryName: fields[3],
+			IataCode:    fields[4],
+			IcaoCode:    fields[5],
+			Latitude:    latitude,
+			Longitude:   longitude,
+			GMT:         fields[9],
+			Timezone:    fields[11],
+		}, nil
+	}, fn)
+}
+
+func (p *OpenFlightsProvider) Countries(_ context.Context, _ func(context.Context, RecordSource[structs.Country]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OpenFlightsProvider) Cities(_ context.Context, _ func(context.Context, RecordSource[structs.City]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OpenFlightsProvider) Taxes(_ context.Context, _ func(context.Context, RecordSource[structs.Tax]) error) error {
+	return ErrDatasetUnsupported
+}
+
+// Airline, Airline ID, Source airport, Source airport ID, Destination
+// airport, Destination airport ID, Codeshare, Stops, Equipment
+func (p *OpenFlightsProvider) Routes(ctx context.Context, fn func(context.Context, RecordSource[structs.Route]) error) error {
+	return readCSV(ctx, p.quarantine, filepath.Join(p.dir, "routes.dat"), 9, func(fields []string) (structs.Route, error) {
+		airlineID, err := atoi(fields[1])
+		if err != nil {
+			return structs.Route{}, fmt.Errorf("invalid airline id: %w", err)
+		}
+		departureID, err := atoi(fields[3])
+		if err != nil {
+			return structs.Route{}, fmt.Errorf("invalid source airport id: %w", err)
+		}
+		arrivalID, err := atoi(fields[5])
+		if err != nil {
+			return structs.Route{}, fmt.Errorf("invalid destination airport id: %w", err)
+		}
+		stops, err := atoi(fields[7])
+		if err != nil {
+			return structs.Route{}, fmt.Errorf("invalid stops: %w", err)
+		}
+
+		return structs.Route{
+			AirlineCode:        fields[0],
+			AirlineId:          airlineID,
+			DepartureIata:      fields[2],
+			DepartureAirportId: departureID,
+			ArrivalIata:        fields[4],
+			ArrivalAirportId:   arrivalID,
+			Codeshare:          fields[6] == "Y",
+			Stops:              stops,
+			Equipment:          fields[8],
+		}, nil
+	}, fn)
+}
+
+// readCSV hands fn a single source over a headerless OpenFlights file and
+// quarantines the lines that do not parse.
+func readCSV[T any](
+	ctx context.Context,
+	quarantine *Quarantine,
+	path string,
+	minFields int,
+	parse func([]string) (T, error),
+	fn func(context.Context, RecordSource[T]) error,
+) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	run := quarantine.run(filepath.Base(path))
+	src := newCSVSource(path, csvReader(f), minFields, func(fields []string) (T, error) {
+		for i, field := range fields {
+			// OpenFlights writes NULL as \N and escapes quotes with a backslash
+			if field == `\N` {
+				fields[i] = ""
+				continue
+			}
+			fields[i] = strings.ReplaceAll(field, `\"`, `"`)
+		}
+		return parse(fields)
+	}, run.reject())
+	if err := fn(ctx, src); err != nil {
+		return err
+	}
+	run.accept(src.accepted)
+	return run.finish(ctx)
+}
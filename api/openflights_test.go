@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openFlightsFixture returns a provider over a directory holding the given
+// data files.
+func openFlightsFixture(t *testing.T, files map[string]string) *OpenFlightsProvider {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOpenFlightsProvider(dir, nil)
+}
+
+// collect returns every record a provider method hands out.
+func collect[T any](read func(context.Context, func(context.Context, RecordSource[T]) error) error) ([]T, error) {
+	var records []T
+	err := read(context.Background(), func(_ context.Context, src RecordSource[T]) error {
+		for src.Next() {
+			records = append(records, src.Record())
+		}
+		return src.Err()
+	})
+	return records, err
+}
+
+func TestOpenFlightsAirports(t *testing.T) {
+	provider := openFlightsFixture(t, map[string]string{"airports.dat": `` +
+		`1,"Goroka Airport","Goroka","Papua New Guinea","GKA","AYGA",-6.081689834590001,145.391998291,5282,10,"U","Pacific/Port_Moresby","airport","OurAirports"` + "\n" +
+		`5,"Lisbon \"Humberto Delgado\" Airport","Lisbon","Portugal","LIS","LPPT",38.7813,-9.13592,374,0,"E","Europe/Lisbon","airport","OurAirports"` + "\n" +
+		`641,"Harstad/Narvik Airport, Evenes","Harstad/Narvik","Norway",\N,"ENEV",68.491302490234,16.678100585938,84,1,"E","Europe/Oslo","airport","OurAirports"` + "\n",
+	})
+
+	airports, err := collect(provider.Airports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(airports) != 3 {
+		t.Fatalf("got %d airports, want 3", len(airports))
+	}
+
+	if a := airports[0]; a.AirportId != 1 || a.IataCode != "GKA" || a.IcaoCode != "AYGA" ||
+		a.Latitude != -6.081689834590001 || a.GMT != "10" || a.Timezone != "Pacific/Port_Moresby" {
+		t.Errorf("got airport %+v", a)
+	}
+	if name := airports[1].AirportName; name != `Lisbon "Humberto Delgado" Airport` {
+		t.Errorf("got name %q, want the escaped quotes unescaped", name)
+	}
+	if a := airports[2]; a.AirportName != "Harstad/Narvik Airport, Evenes" || a.IataCode != "" {
+		t.Errorf("got name %q and IATA %q, want the quoted comma kept and \\N read as empty", a.AirportName, a.IataCode)
+	}
+}
+
+func TestOpenFlightsRoutes(t *testing.T) {
+	provider := openFlightsFixture(t, map[string]string{"routes.dat": "" +
+		"TP,4781,LIS,1638,OPO,1636,,0,319 320\n" +
+		"2B,410,AER,2965,KZN,2990,Y,0,CR2\n" +
+		"ZM,\\N,FRU,2912,OSS,2913,,0,734\n",
+	})
+
+	routes, err := collect(provider.Routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []structs.Route{
+		{AirlineCode: "TP", AirlineId: 4781, DepartureIata: "LIS", DepartureAirportId: 1638,
+			ArrivalIata: "OPO", ArrivalAirportId: 1636, Equipment: "319 320"},
+		{AirlineCode: "2B", AirlineId: 410, DepartureIata: "AER", DepartureAirportId: 2965,
+			ArrivalIata: "KZN", ArrivalAirportId: 2990, Codeshare: true, Equipment: "CR2"},
+		{AirlineCode: "ZM", DepartureIata: "FRU", DepartureAirportId: 2912,
+			ArrivalIata: "OSS", ArrivalAirportId: 2913, Equipment: "734"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want))
+	}
+	for i := range want {
+		if routes[i] != want[i] {
+			t.Errorf("got route %+v, want %+v", routes[i], want[i])
+		}
+	}
+}
+
+func TestOpenFlightsAirlines(t *testing.T) {
+	provider := openFlightsFixture(t, map[string]string{"airlines.dat": "" +
+		`1,"Private flight",\N,"-","N/A","","","Y"` + "\n" +
+		`4781,"TAP Portugal","TAP Air Portugal","TP","TAP","AIR PORTUGAL","Portugal","N"` + "\n",
+	})
+
+	airlines, err := collect(provider.Airlines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(airlines) != 2 {
+		t.Fatalf("got %d airlines, want 2", len(airlines))
+	}
+	if a := airlines[0]; a.AirlineId != 1 || a.Status != "active" {
+		t.Errorf("got airline %+v, want active", a)
+	}
+	if a := airlines[1]; a.AirlineName != "TAP Portugal" || a.IataCode != "TP" || a.IcaoCode != "TAP" ||
+		a.Callsign != "AIR PORTUGAL" || a.CountryName != "Portugal" || a.Status != "inactive" {
+		t.Errorf("got airline %+v", a)
+	}
+}
+
+func TestOpenFlightsMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		read    func(*OpenFlightsProvider) error
+		wantErr string
+	}{
+		{
+			name:    "airport latitude",
+			file:    "airports.dat",
+			content: `7,"Narsarsuaq Airport","Narssarssuaq","Greenland","UAK","BGBW",north,-45.4259,112,-3,"E","America/Godthab","airport","OurAirports"` + "\n",
+			read: func(p *OpenFlightsProvider) error {
+				_, err := collect(p.Airports)
+				return err
+			},
+			wantErr: "airports.dat:1: invalid latitude",
+		},
+		{
+			name:    "route missing fields",
+			file:    "routes.dat",
+			content: "TP,4781,LIS,1638,OPO\n",
+			read: func(p *OpenFlightsProvider) error {
+				_, err := collect(p.Routes)
+				return err
+			},
+			wantErr: "routes.dat:1: expected 9 fields, got 5",
+		},
+		{
+			name:    "aircraft without codes",
+			file:    "planes.dat",
+			content: `"Boeing 737-800","738","B738"` + "\n" + `"Unknown",\N,\N` + "\n",
+			read: func(p *OpenFlightsProvider) error {
+				_, err := collect(p.Aircraft)
+				return err
+			},
+			wantErr: "planes.dat:2: no IATA or ICAO code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// without a quarantine a malformed line fails the import
+			provider := openFlightsFixture(t, map[string]string{tt.file: tt.content})
+			err := tt.read(provider)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenFlightsAircraft(t *testing.T) {
+	provider := openFlightsFixture(t, map[string]string{"planes.dat": "" +
+		`"Boeing 737-800","738","B738"` + "\n" +
+		`"Aerospatiale (Nord) 262","ND2",\N` + "\n",
+	})
+
+	aircraft, err := collect(provider.Aircraft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aircraft) != 2 {
+		t.Fatalf("got %d aircraft, want 2", len(aircraft))
+	}
+	if id, _ := planeTypeID("738", "B738"); aircraft[0].PlaneTypeId != id {
+		t.Errorf("got plane_type_id %d, want the ICAO designator's %d", aircraft[0].PlaneTypeId, id)
+	}
+	if id, _ := planeTypeID("ND2", ""); aircraft[1].PlaneTypeId != id || aircraft[1].IataCode != "ND2" {
+		t.Errorf("got aircraft %+v, want the IATA code's id %d", aircraft[1], id)
+	}
+}
+
+func TestPlaneTypeID(t *testing.T) {
+	tests := []struct {
+		name      string
+		iata      string
+		icao      string
+		wantError bool
+	}{
+		{name: "icao", iata: "738", icao: "B738"},
+		{name: "iata only", iata: "ND2"},
+		{name: "lowercase", icao: "a320"},
+		{name: "no codes", wantError: true},
+		{name: "too long", icao: "B7378", wantError: true},
+		{name: "invalid character", icao: "B-38", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := planeTypeID(tt.iata, tt.icao)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("got id %d, want an error", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id >= 0 {
+				t.Errorf("got id %d, want it negative", id)
+			}
+		})
+	}
+}
+
+func TestPlaneTypeIDDistinct(t *testing.T) {
+	upper, _ := planeTypeID("", "A320")
+	lower, _ := planeTypeID("", "a320")
+	if upper != lower {
+		t.Errorf("got %d and %d, want the case ignored", upper, lower)
+	}
+	// the ICAO code wins over the IATA one
+	withIATA, _ := planeTypeID("320", "A320")
+	if upper != withIATA {
+		t.Errorf("got %d and %d, want the IATA code ignored next to an ICAO one", upper, withIATA)
+	}
+
+	seen := make(map[int]string)
+	for _, codes := range [][2]string{
+		{"", "A"}, {"", "0"}, {"", "A0"}, {"", "0A"}, {"", "ZZZZ"}, {"", "9999"}, {"", "B738"},
+		{"A", ""}, {"0", ""}, {"A0", ""}, {"738", ""}, {"B738", ""},
+	} {
+		id, err := planeTypeID(codes[0], codes[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := "iata " + codes[0] + " icao " + codes[1]
+		if other, ok := seen[id]; ok {
+			t.Errorf("got id %d for both %s and %s", id, other, key)
+		}
+		seen[id] = key
+	}
+}
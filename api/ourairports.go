@@ -0,0 +1,420 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OurAirportsProvider imports the OurAirports dumps (airports.csv,
+// runways.csv, airport-frequencies.csv and navaids.csv) from a directory on
+// disk, see https://ourairports.com/data. As a ReferenceProvider it only has
+// airports; runways, frequencies and navaids have their own tables. Lines
+// that do not parse, and runways or frequencies of an airport missing from
+// airports.csv, are quarantined.
+type OurAirportsProvider struct {
+	dir        string
+	quarantine *Quarantine
+}
+
+func NewOurAirportsProvider(dir string, quarantine *Quarantine) *OurAirportsProvider {
+	return &OurAirportsProvider{dir: dir, quarantine: quarantine}
+}
+
+func (p *OurAirportsProvider) Name() string {
+	return "ourairports"
+}
+
+// Airports reads airports.csv. The ident is kept as is, the ICAO code comes
+// from icao_code or, for airports without one, gps_code.
+func (p *OurAirportsProvider) Airports(ctx context.Context, fn func(context.Context, RecordSource[structs.Airport]) error) error {
+	columns := []string{"id", "ident", "name", "latitude_deg", "longitude_deg", "iso_country", "iata_code",
+		"icao_code", "gps_code",
+	}
+	return readHeaderCSV(ctx, p.quarantine, filepath.Join(p.dir, "airports.csv"), columns, func(row csvRow) (structs.Airport, error) {
+		id, err := row.intValue("id")
+		if err != nil {
+			return structs.Airport{}, err
+		}
+		latitude, err := row.floatPtr("latitude_deg")
+		if err != nil || latitude == nil {
+			return structs.Airport{}, fmt.Errorf("invalid latitude_deg: %w", err)
+		}
+		longitude, err := row.floatPtr("longitude_deg")
+		if err != nil || longitude == nil {
+			return structs.Airport{}, fmt.Errorf("invalid longitude_deg: %w", err)
+		}
+
+		icao := row.str("icao_code")
+		if icao == "" {
+			icao = row.str("gps_code")
+		}
+
+		return structs.Airport{
+			AirportId:   id,
+			AirportName: row.str("name"),
+			Ident:       row.str("ident"),
+			IcaoCode:    icao,
+			IataCode:    row.str("iata_code"),
+			CountryISO2: row.str("iso_country"),
+			Latitude:    *latitude,
+			Longitude:   *longitude,
+		}, nil
+	}, fn)
+}
+
+func (p *OurAirportsProvider) Airlines(_ context.Context, _ func(context.Context, RecordSource[structs.Airline]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Aircraft(_ context.Context, _ func(context.Context, RecordSource[structs.Aircraft]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Airplanes(_ context.Context, _ func(context.Context, RecordSource[structs.Airplane]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Countries(_ context.Context, _ func(context.Context, RecordSource[structs.Country]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Cities(_ context.Context, _ func(context.Context, RecordSource[structs.City]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Taxes(_ context.Context, _ func(context.Context, RecordSource[structs.Tax]) error) error {
+	return ErrDatasetUnsupported
+}
+
+func (p *OurAirportsProvider) Routes(_ context.Context, _ func(context.Context, RecordSource[structs.Route]) error) error {
+	return ErrDatasetUnsupported
+}
+
+// airportIdents returns the ident of every airport in airports.csv, the
+// runways and frequencies refer to their airport by it.
+func (p *OurAirportsProvider) airportIdents() (map[string]bool, error) {
+	path := filepath.Join(p.dir, "airports.csv")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	reader := csvReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s header: %w", path, err)
+	}
+	column := slices.IndexFunc(header, func(name string) bool { return strings.TrimSpace(name) == "ident" })
+	if column < 0 {
+		return nil, fmt.Errorf("%s: missing column %q", path, "ident")
+	}
+
+	idents := make(map[string]bool)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return idents, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if column < len(fields) {
+			idents[strings.TrimSpace(fields[column])] = true
+		}
+	}
+}
+
+// airportIdent reads the airport_ident of row, which must name an airport in
+// idents.
+func airportIdent(row csvRow, idents map[string]bool) (string, error) {
+	ident := row.str("airport_ident")
+	if !idents[ident] {
+		return "", fmt.Errorf("unknown airport_ident %q", ident)
+	}
+	return ident, nil
+}
+
+func (p *OurAirportsProvider) Runways(ctx context.Context, fn func(context.Context, RecordSource[structs.Runway]) error) error {
+	columns := []string{"id", "airport_ident", "length_ft", "width_ft", "surface", "lighted", "closed",
+		"le_ident", "le_latitude_deg", "le_longitude_deg", "le_elevation_ft", "le_heading_degT",
+		"le_displaced_threshold_ft", "he_ident", "he_latitude_deg", "he_longitude_deg", "he_elevation_ft",
+		"he_heading_degT", "he_displaced_threshold_ft",
+	}
+	idents, err := p.airportIdents()
+	if err != nil {
+		return err
+	}
+	return readHeaderCSV(ctx, p.quarantine, filepath.Join(p.dir, "runways.csv"), columns, func(row csvRow) (structs.Runway, error) {
+		ident, err := airportIdent(row, idents)
+		if err != nil {
+			return structs.Runway{}, err
+		}
+		runway := structs.Runway{
+			AirportIdent: ident,
+			Surface:      row.str("surface"),
+			Lighted:      row.str("lighted") == "1",
+			Closed:       row.str("closed") == "1",
+			LeIdent:      row.str("le_ident"),
+			HeIdent:      row.str("he_ident"),
+		}
+
+		ints := map[string]**int{
+			"length_ft":                 &runway.LengthFt,
+			"width_ft":                  &runway.WidthFt,
+			"le_elevation_ft":           &runway.LeElevationFt,
+			"le_displaced_threshold_ft": &runway.LeDisplacedThresholdFt,
+			"he_elevation_ft":           &runway.HeElevationFt,
+			"he_displaced_threshold_ft": &runway.HeDisplacedThresholdFt,
+		}
+		floats := map[string]**float64{
+			"le_latitude_deg":  &runway.LeLatitude,
+			"le_longitude_deg": &runway.LeLongitude,
+			"le_heading_degT":  &runway.LeHeadingDeg,
+			"he_latitude_deg":  &runway.HeLatitude,
+			"he_longitude_deg": &runway.HeLongitude,
+			"he_heading_degT":  &runway.HeHeadingDeg,
+		}
+
+		if runway.RunwayId, err = row.intValue("id"); err != nil {
+			return structs.Runway{}, err
+		}
+		for name, field := range ints {
+			if *field, err = row.intPtr(name); err != nil {
+				return structs.Runway{}, err
+			}
+		}
+		for name, field := range floats {
+			if *field, err = row.floatPtr(name); err != nil {
+				return structs.Runway{}, err
+			}
+		}
+
+		return runway, nil
+	}, fn)
+}
+
+func (p *OurAirportsProvider) Frequencies(ctx context.Context, fn func(context.Context, RecordSource[structs.AirportFrequency]) error) error {
+	columns := []string{"id", "airport_ident", "type", "description", "frequency_mhz"}
+	idents, err := p.airportIdents()
+	if err != nil {
+		return err
+	}
+	return readHeaderCSV(ctx, p.quarantine, filepath.Join(p.dir, "airport-frequencies.csv"), columns,
+		func(row csvRow) (structs.AirportFrequency, error) {
+			id, err := row.intValue("id")
+			if err != nil {
+				return structs.AirportFrequency{}, err
+			}
+			ident, err := airportIdent(row, idents)
+			if err != nil {
+				return structs.AirportFrequency{}, err
+			}
+			frequency, err := row.floatPtr("frequency_mhz")
+			if err != nil {
+				return structs.AirportFrequency{}, err
+			}
+
+			return structs.AirportFrequency{
+				FrequencyId:  id,
+				AirportIdent: ident,
+				Type:         row.str("type"),
+				Description:  row.str("description"),
+				FrequencyMhz: frequency,
+			}, nil
+		}, fn)
+}
+
+func (p *OurAirportsProvider) Navaids(ctx context.Context, fn func(context.Context, RecordSource[structs.Navaid]) error) error {
+	columns := []string{"id", "ident", "name", "type", "frequency_khz", "latitude_deg", "longitude_deg",
+		"elevation_ft", "iso_country", "dme_frequency_khz", "dme_channel", "magnetic_variation_deg",
+		"usageType", "power", "associated_airport",
+	}
+	return readHeaderCSV(ctx, p.quarantine, filepath.Join(p.dir, "navaids.csv"), columns, func(row csvRow) (structs.Navaid, error) {
+		var err error
+		navaid := structs.Navaid{
+			Ident:             row.str("ident"),
+			NavaidName:        row.str("name"),
+			Type:              row.str("type"),
+			IsoCountry:        row.str("iso_country"),
+			DmeChannel:        row.str("dme_channel"),
+			UsageType:         row.str("usageType"),
+			Power:             row.str("power"),
+			AssociatedAirport: row.str("associated_airport"),
+		}
+
+		if navaid.NavaidId, err = row.intValue("id"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.FrequencyKhz, err = row.intPtr("frequency_khz"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.DmeFrequencyKhz, err = row.intPtr("dme_frequency_khz"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.ElevationFt, err = row.intPtr("elevation_ft"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.Latitude, err = row.floatPtr("latitude_deg"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.Longitude, err = row.floatPtr("longitude_deg"); err != nil {
+			return structs.Navaid{}, err
+		}
+		if navaid.MagneticVariationDeg, err = row.floatPtr("magnetic_variation_deg"); err != nil {
+			return structs.Navaid{}, err
+		}
+
+		return navaid, nil
+	}, fn)
+}
+
+var runwayColumns = []string{"runway_id", "airport_ident", "length_ft", "width_ft", "surface", "lighted", "closed",
+	"le_ident", "le_latitude", "le_longitude", "le_elevation_ft", "le_heading_deg", "le_displaced_threshold_ft",
+	"he_ident", "he_latitude", "he_longitude", "he_elevation_ft", "he_heading_deg", "he_displaced_threshold_ft",
+	"created_at",
+}
+
+func runwayRow(r structs.Runway) []any {
+	return []any{
+		r.RunwayId, r.AirportIdent, r.LengthFt, r.WidthFt, r.Surface, r.Lighted, r.Closed,
+		r.LeIdent, r.LeLatitude, r.LeLongitude, r.LeElevationFt, r.LeHeadingDeg, r.LeDisplacedThresholdFt,
+		r.HeIdent, r.HeLatitude, r.HeLongitude, r.HeElevationFt, r.HeHeadingDeg, r.HeDisplacedThresholdFt,
+		formatTime(time.Now()),
+	}
+}
+
+var frequencyColumns = []string{"frequency_id", "airport_ident", "type", "description", "frequency_mhz", "created_at"}
+
+func frequencyRow(f structs.AirportFrequency) []any {
+	return []any{f.FrequencyId, f.AirportIdent, f.Type, f.Description, f.FrequencyMhz, formatTime(time.Now())}
+}
+
+var navaidColumns = []string{"navaid_id", "ident", "navaid_name", "type", "frequency_khz", "latitude", "longitude",
+	"elevation_ft", "iso_country", "dme_frequency_khz", "dme_channel", "magnetic_variation_deg", "usage_type", "power",
+	"associated_airport", "created_at",
+}
+
+func navaidRow(n structs.Navaid) []any {
+	return []any{
+		n.NavaidId, n.Ident, n.NavaidName, n.Type, n.FrequencyKhz, n.Latitude, n.Longitude,
+		n.ElevationFt, n.IsoCountry, n.DmeFrequencyKhz, n.DmeChannel, n.MagneticVariationDeg, n.UsageType, n.Power,
+		n.AssociatedAirport, formatTime(time.Now()),
+	}
+}
+
+func FetchAndInsertRunwayData(ctx context.Context, conn *pgxpool.Pool, provider *OurAirportsProvider) error {
+	return insertFromProvider(ctx, conn, provider, "runway", provider.Runways, runwayColumns, runwayRow)
+}
+
+func FetchAndInsertFrequencyData(ctx context.Context, conn *pgxpool.Pool, provider *OurAirportsProvider) error {
+	return insertFromProvider(ctx, conn, provider, "airport_frequency", provider.Frequencies, frequencyColumns, frequencyRow)
+}
+
+func FetchAndInsertNavaidData(ctx context.Context, conn *pgxpool.Pool, provider *OurAirportsProvider) error {
+	return insertFromProvider(ctx, conn, provider, "navaid", provider.Navaids, navaidColumns, navaidRow)
+}
+
+// AirportRepository reads airports together with their OurAirports details.
+type AirportRepository struct {
+	conn *pgxpool.Pool
+}
+
+func NewAirportRepository(conn *pgxpool.Pool) *AirportRepository {
+	return &AirportRepository{conn: conn}
+}
+
+// AirportByICAO returns the airport with the given ICAO code, including its
+// runways, radio frequencies and associated navaids. These are matched on
+// the airport's OurAirports ident, or its ICAO code when it has none.
+func (r *AirportRepository) AirportByICAO(ctx context.Context, icao string) (*structs.Airport, error) {
+	var airport structs.Airport
+	var phoneNumber *string
+	if err := r.conn.QueryRow(ctx, `
+		select id::text, coalesce(gmt, ''), airport_id, coalesce(iata_code, ''), coalesce(city_iata_code, ''),
+			icao_code, coalesce(nullif(ident, ''), icao_code), coalesce(country_iso2, ''), coalesce(geoname_id, ''),
+			latitude, longitude, coalesce(airport_name, ''), coalesce(country_name, ''), phone_number,
+			coalesce(timezone, '')
+		from airport where icao_code = $1 and deleted_at is null limit 1
+		`, icao,
+	).Scan(
+		&airport.ID, &airport.GMT, &airport.AirportId, &airport.IataCode, &airport.CityIataCode,
+		&airport.IcaoCode, &airport.Ident, &airport.CountryISO2, &airport.GeonameID, &airport.Latitude,
+		&airport.Longitude, &airport.AirportName, &airport.CountryName, &phoneNumber, &airport.Timezone,
+	); err != nil {
+		return nil, err
+	}
+	if phoneNumber != nil {
+		airport.PhoneNumber = *phoneNumber
+	}
+
+	rows, _ := r.conn.Query(ctx, `
+		select id::text, runway_id, airport_ident, length_ft, width_ft, coalesce(surface, ''), lighted, closed,
+			coalesce(le_ident, ''), le_latitude, le_longitude, le_elevation_ft, le_heading_deg,
+			le_displaced_threshold_ft, coalesce(he_ident, ''), he_latitude, he_longitude, he_elevation_ft,
+			he_heading_deg, he_displaced_threshold_ft
+		from runway where airport_ident = $1 order by le_ident
+		`, airport.Ident,
+	)
+	var runway structs.Runway
+	if _, err := pgx.ForEachRow(rows, []any{
+		&runway.ID, &runway.RunwayId, &runway.AirportIdent, &runway.LengthFt, &runway.WidthFt, &runway.Surface,
+		&runway.Lighted, &runway.Closed, &runway.LeIdent, &runway.LeLatitude, &runway.LeLongitude,
+		&runway.LeElevationFt, &runway.LeHeadingDeg, &runway.LeDisplacedThresholdFt, &runway.HeIdent,
+		&runway.HeLatitude, &runway.HeLongitude, &runway.HeElevationFt, &runway.HeHeadingDeg,
+		&runway.HeDisplacedThresholdFt,
+	}, func() error {
+		airport.Runways = append(airport.Runways, runway)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("error querying runways: %w", err)
+	}
+
+	rows, _ = r.conn.Query(ctx, `
+		select id::text, frequency_id, airport_ident, coalesce(type, ''), coalesce(description, ''), frequency_mhz
+		from airport_frequency where airport_ident = $1 order by type
+		`, airport.Ident,
+	)
+	var frequency structs.AirportFrequency
+	if _, err := pgx.ForEachRow(rows, []any{
+		&frequency.ID, &frequency.FrequencyId, &frequency.AirportIdent, &frequency.Type, &frequency.Description,
+		&frequency.FrequencyMhz,
+	}, func() error {
+		airport.Frequencies = append(airport.Frequencies, frequency)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("error querying frequencies: %w", err)
+	}
+
+	rows, _ = r.conn.Query(ctx, `
+		select id::text, navaid_id, coalesce(ident, ''), coalesce(navaid_name, ''), coalesce(type, ''),
+			frequency_khz, latitude, longitude, elevation_ft, coalesce(iso_country, ''), dme_frequency_khz,
+			coalesce(dme_channel, ''), magnetic_variation_deg, coalesce(usage_type, ''), coalesce(power, ''),
+			associated_airport
+		from navaid where associated_airport = $1 order by ident
+		`, airport.Ident,
+	)
+	var navaid structs.Navaid
+	if _, err := pgx.ForEachRow(rows, []any{
+		&navaid.ID, &navaid.NavaidId, &navaid.Ident, &navaid.NavaidName, &navaid.Type, &navaid.FrequencyKhz,
+		&navaid.Latitude, &navaid.Longitude, &navaid.ElevationFt, &navaid.IsoCountry, &navaid.DmeFrequencyKhz,
+		&navaid.DmeChannel, &navaid.MagneticVariationDeg, &navaid.UsageType, &navaid.Power,
+		&navaid.AssociatedAirport,
+	}, func() error {
+		airport.Navaids = append(airport.Navaids, navaid)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("error querying navaids: %w", err)
+	}
+
+	return &airport, nil
+}
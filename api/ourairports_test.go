@@ -0,0 +1,127 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ourAirportsHeader = `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","continent",` +
+	`"iso_country","iso_region","municipality","scheduled_service","icao_code","iata_code","gps_code","local_code"` + "\n"
+
+// ourAirportsFixture returns a provider over a directory holding the given
+// dumps.
+func ourAirportsFixture(t *testing.T, files map[string]string) *OurAirportsProvider {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOurAirportsProvider(dir, nil)
+}
+
+func TestOurAirportsAirports(t *testing.T) {
+	provider := ourAirportsFixture(t, map[string]string{"airports.csv": ourAirportsHeader +
+		`2434,"LPPT","large_airport","Humberto Delgado Airport",38.7813,-9.13592,374,"EU","PT","PT-11","Lisbon","yes","LPPT","LIS","LPPT",""` + "\n" +
+		`322411,"US-0391","small_airport","Wolf Creek Airport",37.47,-106.79,10640,"NA","US","US-CO","Pagosa Springs","no","","","CO91","CO91"` + "\n" +
+		`6523,"00A","heliport","Total RF Heliport",40.07,-74.93,11,"NA","US","US-PA","Bensalem","no","","","","00A"` + "\n",
+	})
+
+	airports, err := collect(provider.Airports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ ident, icao string }{{"LPPT", "LPPT"}, {"US-0391", "CO91"}, {"00A", ""}}
+	if len(airports) != len(want) {
+		t.Fatalf("got %d airports, want %d", len(airports), len(want))
+	}
+	for i, w := range want {
+		if airports[i].Ident != w.ident || airports[i].IcaoCode != w.icao {
+			t.Errorf("got ident %q and ICAO %q, want %q and %q", airports[i].Ident, airports[i].IcaoCode, w.ident, w.icao)
+		}
+	}
+	if a := airports[0]; a.AirportId != 2434 || a.IataCode != "LIS" || a.CountryISO2 != "PT" {
+		t.Errorf("got airport %+v", a)
+	}
+}
+
+func TestOurAirportsRejected(t *testing.T) {
+	airports := ourAirportsHeader +
+		`2434,"LPPT","large_airport","Humberto Delgado Airport",38.7813,-9.13592,374,"EU","PT","PT-11","Lisbon","yes","LPPT","LIS","LPPT",""` + "\n"
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		read    func(*OurAirportsProvider) error
+		wantErr string
+	}{
+		{
+			name: "blank airport id",
+			files: map[string]string{"airports.csv": ourAirportsHeader +
+				`,"LPPR","large_airport","Francisco Sa Carneiro Airport",41.24,-8.68,228,"EU","PT","PT-13","Porto","yes","LPPR","OPO","LPPR",""` + "\n"},
+			read: func(p *OurAirportsProvider) error {
+				_, err := collect(p.Airports)
+				return err
+			},
+			wantErr: "airports.csv:2: missing id",
+		},
+		{
+			name: "runway of an unknown airport",
+			files: map[string]string{
+				"airports.csv": airports,
+				"runways.csv": "id,airport_ref,airport_ident,length_ft,width_ft,surface,lighted,closed,le_ident," +
+					"le_latitude_deg,le_longitude_deg,le_elevation_ft,le_heading_degT,le_displaced_threshold_ft,he_ident," +
+					"he_latitude_deg,he_longitude_deg,he_elevation_ft,he_heading_degT,he_displaced_threshold_ft\n" +
+					"234512,2434,LPPT,12484,148,ASP,1,0,03,38.77,-9.14,374,25,,21,38.79,-9.12,339,205,\n" +
+					"234513,2435,LPPR,11417,148,ASP,1,0,17,41.25,-8.68,228,172,,35,41.23,-8.67,201,352,\n",
+			},
+			read: func(p *OurAirportsProvider) error {
+				_, err := collect(p.Runways)
+				return err
+			},
+			wantErr: `runways.csv:3: unknown airport_ident "LPPR"`,
+		},
+		{
+			name: "frequency of an unknown airport",
+			files: map[string]string{
+				"airports.csv": airports,
+				"airport-frequencies.csv": "id,airport_ref,airport_ident,type,description,frequency_mhz\n" +
+					"60245,2434,LPPT,TWR,LISBOA TOWER,118.1\n" +
+					"60246,9999,,ATIS,ATIS,124.15\n",
+			},
+			read: func(p *OurAirportsProvider) error {
+				_, err := collect(p.Frequencies)
+				return err
+			},
+			wantErr: `airport-frequencies.csv:3: unknown airport_ident ""`,
+		},
+		{
+			name: "blank runway id",
+			files: map[string]string{
+				"airports.csv": airports,
+				"runways.csv": "id,airport_ref,airport_ident,length_ft,width_ft,surface,lighted,closed,le_ident," +
+					"le_latitude_deg,le_longitude_deg,le_elevation_ft,le_heading_degT,le_displaced_threshold_ft,he_ident," +
+					"he_latitude_deg,he_longitude_deg,he_elevation_ft,he_heading_degT,he_displaced_threshold_ft\n" +
+					",2434,LPPT,12484,148,ASP,1,0,03,38.77,-9.14,374,25,,21,38.79,-9.12,339,205,\n",
+			},
+			read: func(p *OurAirportsProvider) error {
+				_, err := collect(p.Runways)
+				return err
+			},
+			wantErr: "runways.csv:2: missing id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// without a quarantine a rejected line fails the import
+			err := tt.read(ourAirportsFixture(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"sync"
+)
+
+// pageFunc consumes one page body and reports the pagination it carried.
+type pageFunc func(ctx context.Context, body io.Reader) (structs.Pagination, error)
+
+// fetchPages walks every page of endpoint and hands each page body to fn.
+// The first page is consumed on its own to learn the total, the remaining
+// pages are fetched with up to c.concurrency requests in flight, so fn may
+// be called concurrently. The first failure cancels the pages in flight.
+// With a pageProgress in ctx the walk starts at its offset and reports
+// every page fn consumed.
+func (c *AviationStackClient) fetchPages(ctx context.Context, endpoint string, fn pageFunc, queryParams ...string) error {
+	progress := pageProgressFrom(ctx)
+	start := 0
+	if progress != nil {
+		start = progress.from
+	}
+
+	pagination, err := c.fetchPage(ctx, endpoint, start, c.pageSize, fn, queryParams...)
+	if err != nil {
+		return err
+	}
+
+	// The API caps the limit per plan, so step by what it actually returned
+	step := pagination.Limit
+	if step <= 0 || step > c.pageSize {
+		step = c.pageSize
+	}
+	progress.pageDone(ctx, start, start+step, pagination.Total)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+
+	for offset := start + step; offset < pagination.Total; offset += step {
+		offset := offset
+		g.Go(func() error {
+			page, err := c.fetchPage(ctx, endpoint, offset, step, fn, queryParams...)
+			if err != nil {
+				return err
+			}
+			progress.pageDone(ctx, offset, offset+step, page.Total)
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+type pageProgressKey struct{}
+
+// pageProgress tracks a fetchPages walk that resumes at from. save is
+// called, one call at a time, with the offset below which fn consumed every
+// page, each time it moves.
+type pageProgress struct {
+	from int
+	save func(ctx context.Context, next, total int)
+
+	mu   sync.Mutex
+	next int
+	// done maps the offset of a consumed page past next to where it ends
+	done map[int]int
+}
+
+// withPageProgress returns a context whose fetchPages walk starts at from
+// and reports its progress to save.
+func withPageProgress(ctx context.Context, from int, save func(ctx context.Context, next, total int)) context.Context {
+	progress := &pageProgress{from: from, save: save, next: from, done: make(map[int]int)}
+	return context.WithValue(ctx, pageProgressKey{}, progress)
+}
+
+func pageProgressFrom(ctx context.Context) *pageProgress {
+	progress, _ := ctx.Value(pageProgressKey{}).(*pageProgress)
+	return progress
+}
+
+// pageDone records that the page from offset to end was consumed. Pages
+// finish out of order, so next only moves once the pages before are done.
+func (p *pageProgress) pageDone(ctx context.Context, offset, end, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done[offset] = end
+	moved := false
+	for {
+		end, ok := p.done[p.next]
+		if !ok {
+			break
+		}
+		delete(p.done, p.next)
+		p.next = end
+		moved = true
+	}
+	if moved {
+		p.save(ctx, p.next, total)
+	}
+}
+
+func (c *AviationStackClient) fetchPage(
+	ctx context.Context,
+	endpoint string,
+	offset, limit int,
+	fn pageFunc,
+	queryParams ...string,
+) (structs.Pagination, error) {
+	params := append([]string{
+		fmt.Sprintf("offset=%d", offset),
+		fmt.Sprintf("limit=%d", limit),
+	}, queryParams...)
+
+	body, err := c.open(ctx, endpoint, params...)
+	if err != nil {
+		return structs.Pagination{}, err
+	}
+	defer body.Close()
+
+	return fn(ctx, body)
+}
+
+// staged is what stageChanged copied from an endpoint.
+type staged struct {
+	// table is the temporary table holding the records
+	table string
+	// rejected is the number of malformed records that were quarantined
+	rejected int
+	// commit records the pages as synced, call it once tx has committed
+	commit func()
+}
+
+// stageChanged copies every page of endpoint into a staging table for table
+// within tx as the page arrives, so no more than the pages in flight are
+// held in memory. Pages are fetched concurrently but copied one at a time,
+// tx cannot run two statements at once. With the response cache on it
+// returns ErrNotModified when every page matches what the last committed
+// sync of table wrote; otherwise commit must be called once tx commits.
+func stageChanged[T any](
+	ctx context.Context,
+	tx pgx.Tx,
+	client *AviationStackClient,
+	table, endpoint string,
+	columns []string,
+	row func(T) []any,
+	queryParams ...string,
+) (staged, error) {
+	staging, err := createStaging(ctx, tx, table)
+	if err != nil {
+		return staged{}, err
+	}
+
+	run := client.quarantine.run(endpoint)
+	var mu sync.Mutex
+	pages := make(map[string]string)
+	err = client.fetchPages(ctx, endpoint, func(ctx context.Context, body io.Reader) (structs.Pagination, error) {
+		stream := newRecordStream[T](body, run.reject())
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stageSource(ctx, tx, staging, columns, row, stream); err != nil {
+			return structs.Pagination{}, fmt.Errorf("error staging %s page: %w", endpoint, err)
+		}
+		if cached, ok := body.(*cachedBody); ok {
+			pages[cached.key] = cached.hash
+		}
+		run.accept(stream.Accepted())
+		return stream.Pagination(), nil
+	}, queryParams...)
+	if err != nil {
+		return staged{}, err
+	}
+
+	rejected := run.rejectedCount()
+	if err := run.finish(ctx); err != nil {
+		return staged{}, err
+	}
+	if client.cache == nil {
+		return staged{table: staging, rejected: rejected, commit: func() {}}, nil
+	}
+
+	if client.cache.synced(ctx, table, pages) {
+		return staged{}, ErrNotModified
+	}
+	return staged{
+		table:    staging,
+		rejected: rejected,
+		commit:   func() { client.cache.commit(ctx, table, pages) },
+	}, nil
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPageProgress(t *testing.T) {
+	var saved, totals []int
+	ctx := withPageProgress(context.Background(), 100, func(_ context.Context, next, total int) {
+		saved = append(saved, next)
+		totals = append(totals, total)
+	})
+	progress := pageProgressFrom(ctx)
+	if progress == nil || progress.from != 100 {
+		t.Fatalf("got progress %+v, want one starting at 100", progress)
+	}
+
+	// pages finish out of order, next only moves past consumed pages
+	progress.pageDone(ctx, 100, 200, 500)
+	progress.pageDone(ctx, 300, 400, 500)
+	progress.pageDone(ctx, 400, 500, 500)
+	progress.pageDone(ctx, 200, 300, 510)
+
+	if want := []int{200, 500}; !slices.Equal(saved, want) {
+		t.Errorf("got saves %v, want %v", saved, want)
+	}
+	if want := []int{500, 510}; !slices.Equal(totals, want) {
+		t.Errorf("got totals %v, want %v", totals, want)
+	}
+	if len(progress.done) != 0 {
+		t.Errorf("got pages %v left past next", progress.done)
+	}
+}
+
+func TestPageProgressMissingPage(t *testing.T) {
+	var saved []int
+	ctx := withPageProgress(context.Background(), 0, func(_ context.Context, next, _ int) {
+		saved = append(saved, next)
+	})
+	progress := pageProgressFrom(ctx)
+
+	// the page at 100 never finishes, so nothing past it is saved
+	progress.pageDone(ctx, 200, 300, 300)
+	progress.pageDone(ctx, 0, 100, 300)
+
+	if want := []int{100}; !slices.Equal(saved, want) {
+		t.Errorf("got saves %v, want %v", saved, want)
+	}
+}
+
+func TestPageProgressNil(t *testing.T) {
+	progress := pageProgressFrom(context.Background())
+	if progress != nil {
+		t.Fatalf("got progress %+v without one in the context", progress)
+	}
+	// fetchPages calls it without a progress
+	progress.pageDone(context.Background(), 0, 100, 100)
+}
+
+// pagedServer serves total records of endpoint in pages of at most limit,
+// recording every offset requested.
+type pagedServer struct {
+	total int
+	limit int
+	delay time.Duration
+	// fail answers the page at this offset with a restricted endpoint error
+	fail int
+
+	mu       sync.Mutex
+	offsets  []int
+	inFlight int
+	maxIn    int
+}
+
+func (p *pagedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit = min(limit, p.limit)
+
+	p.mu.Lock()
+	p.offsets = append(p.offsets, offset)
+	p.inFlight++
+	p.maxIn = max(p.maxIn, p.inFlight)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+
+	if offset == p.fail {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(restrictedBody))
+		return
+	}
+	select {
+	case <-time.After(p.delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	count := max(0, min(limit, p.total-offset))
+	records := make([]string, count)
+	for i := range records {
+		records[i] = fmt.Sprintf(`{"id":%d}`, offset+i)
+	}
+	fmt.Fprintf(w, `{"pagination":{"offset":%d,"limit":%d,"count":%d,"total":%d},"data":[%s]}`,
+		offset, limit, count, p.total, strings.Join(records, ","))
+}
+
+// collectIDs is a pageFunc gathering the ids of every page.
+func collectIDs(mu *sync.Mutex, ids map[int]int) pageFunc {
+	return func(_ context.Context, body io.Reader) (structs.Pagination, error) {
+		stream := newRecordStream[streamRecord](body, nil)
+		for stream.Next() {
+			mu.Lock()
+			ids[stream.Record().ID]++
+			mu.Unlock()
+		}
+		return stream.Pagination(), stream.Err()
+	}
+}
+
+func TestFetchPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int
+		// limit is the page size the server caps requests to
+		limit       int
+		wantOffsets int
+	}{
+		{name: "several pages", total: 1050, limit: 100, wantOffsets: 11},
+		{name: "limit capped by the API", total: 230, limit: 50, wantOffsets: 5},
+		{name: "single page", total: 40, limit: 100, wantOffsets: 1},
+		{name: "empty", total: 0, limit: 100, wantOffsets: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &pagedServer{total: tt.total, limit: tt.limit, delay: 5 * time.Millisecond, fail: -1}
+			client := newTestClient(t, server.ServeHTTP, "a")
+
+			var mu sync.Mutex
+			ids := make(map[int]int)
+			if err := client.fetchPages(context.Background(), "airports", collectIDs(&mu, ids)); err != nil {
+				t.Fatal(err)
+			}
+
+			seen := make(map[int]bool)
+			for _, offset := range server.offsets {
+				if seen[offset] {
+					t.Errorf("offset %d fetched more than once", offset)
+				}
+				seen[offset] = true
+			}
+			if len(server.offsets) != tt.wantOffsets {
+				t.Errorf("got offsets %v, want %d pages", server.offsets, tt.wantOffsets)
+			}
+			if len(ids) != tt.total {
+				t.Errorf("got %d records, want %d", len(ids), tt.total)
+			}
+			for id := 0; id < tt.total; id++ {
+				if ids[id] != 1 {
+					t.Errorf("got record %d %d times, want once", id, ids[id])
+				}
+			}
+			if server.maxIn > client.concurrency {
+				t.Errorf("got %d requests in flight, want at most %d", server.maxIn, client.concurrency)
+			}
+			if tt.wantOffsets > 2 && server.maxIn < 2 {
+				t.Errorf("got at most %d request in flight, want pages fetched concurrently", server.maxIn)
+			}
+		})
+	}
+}
+
+func TestFetchPagesError(t *testing.T) {
+	// the pages besides the failing one only answer once cancelled
+	server := &pagedServer{total: 2000, limit: 100, delay: time.Minute, fail: 300}
+	client := newTestClient(t, server.ServeHTTP, "a")
+
+	// the first page is needed to learn the total
+	first := &pagedServer{total: 2000, limit: 100, fail: -1}
+	client.httpClient.Transport = firstPageTransport{first: first, rest: client.httpClient.Transport}
+
+	start := time.Now()
+	var mu sync.Mutex
+	err := client.fetchPages(context.Background(), "airports", collectIDs(&mu, map[int]int{}))
+	if !errors.Is(err, ErrFunctionAccessRestricted) {
+		t.Errorf("got error %v, want the failing page's", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("took %s, want the pages in flight cancelled", elapsed)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.offsets) > client.concurrency+1 {
+		t.Errorf("got offsets %v requested, want no pages started after the failure", server.offsets)
+	}
+}
+
+// firstPageTransport serves the page at offset 0 from first and the rest
+// through rest.
+type firstPageTransport struct {
+	first http.Handler
+	rest  http.RoundTripper
+}
+
+func (f firstPageTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Query().Get("offset") != "0" {
+		return f.rest.RoundTrip(r)
+	}
+	recorder := httptest.NewRecorder()
+	f.first.ServeHTTP(recorder, r)
+	return recorder.Result(), nil
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"github.com/FACorreiaa/go-ollama/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"strings"
+)
+
+var ErrDatasetUnsupported = errors.New("dataset not supported by provider")
+
+// RecordSource yields records one at a time, e.g. an AviationStack page or a CSV file.
+type RecordSource[T any] interface {
+	Next() bool
+	Record() T
+	Err() error
+}
+
+// ReferenceProvider supplies the reference data used to seed the database.
+// Each method hands fn one RecordSource per chunk of the dataset (a page, a
+// file) and returns ErrDatasetUnsupported when the provider has no such data.
+type ReferenceProvider interface {
+	Name() string
+	Airlines(ctx context.Context, fn func(context.Context, RecordSource[structs.Airline]) error) error
+	Aircraft(ctx context.Context, fn func(context.Context, RecordSource[structs.Aircraft]) error) error
+	Airplanes(ctx context.Context, fn func(context.Context, RecordSource[structs.Airplane]) error) error
+	Airports(ctx context.Context, fn func(context.Context, RecordSource[structs.Airport]) error) error
+	Countries(ctx context.Context, fn func(context.Context, RecordSource[structs.Country]) error) error
+	Cities(ctx context.Context, fn func(context.Context, RecordSource[structs.City]) error) error
+	Taxes(ctx context.Context, fn func(context.Context, RecordSource[structs.Tax]) error) error
+	Routes(ctx context.Context, fn func(context.Context, RecordSource[structs.Route]) error) error
+}
+
+// NewReferenceProvider returns the provider configured by cfg.Provider.
+func NewReferenceProvider(cfg *config.ReferenceDataConfig, client *AviationStackClient) (ReferenceProvider, error) {
+	switch cfg.Provider {
+	case "", "aviationstack":
+		return NewAviationStackProvider(client), nil
+	case "openflights":
+		return NewOpenFlightsProvider(cfg.OpenFlightsDir, client.quarantine), nil
+	case "ourairports":
+		if cfg.OurAirportsDir == "" {
+			return nil, fmt.Errorf("the ourairports provider needs OURAIRPORTS_DIR")
+		}
+		return NewOurAirportsProvider(cfg.OurAirportsDir, client.quarantine), nil
+	default:
+		return nil, fmt.Errorf("unknown reference data provider %q", cfg.Provider)
+	}
+}
+
+// copyRecords returns a callback that copies every source it is given into
+// table. Rows whose unique key is already in table are skipped, so a page
+// stored before a seed was interrupted can be copied again when it resumes.
+// Every seeded table needs such a key; without one the rows are duplicated.
+func copyRecords[T any](
+	conn *pgxpool.Pool,
+	table string,
+	columns []string,
+	row func(T) []any,
+) func(context.Context, RecordSource[T]) error {
+	return func(ctx context.Context, src RecordSource[T]) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("error inserting data into %s table: %w", table, err)
+		}
+		defer tx.Rollback(ctx)
+
+		staging := "seed_" + table
+		if _, err := tx.Exec(ctx, fmt.Sprintf(
+			`CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP`,
+			staging, table,
+		)); err != nil {
+			return fmt.Errorf("error creating %s staging table: %w", table, err)
+		}
+		if _, err := tx.CopyFrom(
+			ctx,
+			pgx.Identifier{staging},
+			columns,
+			&copySource[T]{src: src, row: row},
+		); err != nil {
+			return fmt.Errorf("error inserting data into %s table: %w", table, err)
+		}
+
+		list := strings.Join(columns, ", ")
+		if _, err := tx.Exec(ctx, fmt.Sprintf(
+			`INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT DO NOTHING`,
+			table, list, list, staging,
+		)); err != nil {
+			return fmt.Errorf("error inserting data into %s table: %w", table, err)
+		}
+		return tx.Commit(ctx)
+	}
+}
+
+// AviationStackProvider serves reference data from the AviationStack API,
+// one RecordSource per page.
+type AviationStackProvider struct {
+	client *AviationStackClient
+}
+
+func NewAviationStackProvider(client *AviationStackClient) *AviationStackProvider {
+	return &AviationStackProvider{client: client}
+}
+
+func (p *AviationStackProvider) Name() string {
+	return "aviationstack"
+}
+
+func (p *AviationStackProvider) Airlines(ctx context.Context, fn func(context.Context, RecordSource[structs.Airline]) error) error {
+	return streamPages(ctx, p.client, "airlines", fn)
+}
+
+func (p *AviationStackProvider) Aircraft(ctx context.Context, fn func(context.Context, RecordSource[structs.Aircraft]) error) error {
+	return streamPages(ctx, p.client, "aircraft_types", fn)
+}
+
+func (p *AviationStackProvider) Airplanes(ctx context.Context, fn func(context.Context, RecordSource[structs.Airplane]) error) error {
+	return streamPages(ctx, p.client, "airplanes", fn)
+}
+
+func (p *AviationStackProvider) Airports(ctx context.Context, fn func(context.Context, RecordSource[structs.Airport]) error) error {
+	return streamPages(ctx, p.client, "airports", fn)
+}
+
+func (p *AviationStackProvider) Countries(ctx context.Context, fn func(context.Context, RecordSource[structs.Country]) error) error {
+	return streamPages(ctx, p.client, "countries", fn)
+}
+
+func (p *AviationStackProvider) Cities(ctx context.Context, fn func(context.Context, RecordSource[structs.City]) error) error {
+	return streamPages(ctx, p.client, "cities", fn)
+}
+
+func (p *AviationStackProvider) Taxes(ctx context.Context, fn func(context.Context, RecordSource[structs.Tax]) error) error {
+	return streamPages(ctx, p.client, "taxes", fn)
+}
+
+func (p *AviationStackProvider) Routes(_ context.Context, _ func(context.Context, RecordSource[structs.Route]) error) error {
+	return ErrDatasetUnsupported
+}
+
+// streamPages hands fn a recordStream for every page of endpoint. Malformed
+// records are quarantined once every page has been read.
+func streamPages[T any](
+	ctx context.Context,
+	client *AviationStackClient,
+	endpoint string,
+	fn func(context.Context, RecordSource[T]) error,
+	queryParams ...string,
+) error {
+	run := client.quarantine.run(endpoint)
+	err := client.fetchPages(ctx, endpoint, func(ctx context.Context, body io.Reader) (structs.Pagination, error) {
+		stream := newRecordStream[T](body, run.reject())
+		if err := fn(ctx, stream); err != nil {
+			return structs.Pagination{}, err
+		}
+		run.accept(stream.Accepted())
+		return stream.Pagination(), nil
+	}, queryParams...)
+	if err != nil {
+		return err
+	}
+	return run.finish(ctx)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"sync"
+)
+
+// Quarantine stores upstream records that could not be decoded, so one
+// malformed record no longer fails the page it came in.
+type Quarantine struct {
+	conn *pgxpool.Pool
+}
+
+func NewQuarantine(conn *pgxpool.Pool) *Quarantine {
+	return &Quarantine{conn: conn}
+}
+
+type rejectedRecord struct {
+	raw json.RawMessage
+	err error
+}
+
+// quarantineRun collects the records rejected while fetching one dataset.
+// A nil run rejects nothing, so decode errors fail the stream as before.
+type quarantineRun struct {
+	quarantine *Quarantine
+	dataset    string
+
+	mu       sync.Mutex
+	accepted int
+	rejected []rejectedRecord
+}
+
+// run starts collecting the rejected records of dataset. It returns nil on a
+// nil Quarantine.
+func (q *Quarantine) run(dataset string) *quarantineRun {
+	if q == nil {
+		return nil
+	}
+	return &quarantineRun{quarantine: q, dataset: dataset}
+}
+
+// reject returns the callback a recordStream hands its malformed records to,
+// or nil when there is no run.
+func (r *quarantineRun) reject() func(json.RawMessage, error) {
+	if r == nil {
+		return nil
+	}
+	return func(raw json.RawMessage, err error) {
+		r.mu.Lock()
+		r.rejected = append(r.rejected, rejectedRecord{raw: raw, err: err})
+		r.mu.Unlock()
+	}
+}
+
+func (r *quarantineRun) accept(n int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.accepted += n
+	r.mu.Unlock()
+}
+
+// rejectedCount returns how many records of the run were rejected so far.
+func (r *quarantineRun) rejectedCount() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.rejected)
+}
+
+// finish writes the rejected records to the quarantine table and logs how
+// many records of the run were accepted and rejected.
+func (r *quarantineRun) finish(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.rejected) == 0 {
+		slog.Info("Decoded records", "dataset", r.dataset, "accepted", r.accepted, "rejected", 0)
+		return nil
+	}
+
+	rows := make([][]any, len(r.rejected))
+	for i, record := range r.rejected {
+		rows[i] = []any{r.dataset, string(record.raw), record.err.Error()}
+	}
+	if _, err := r.quarantine.conn.CopyFrom(
+		ctx,
+		pgx.Identifier{"quarantine"},
+		[]string{"dataset", "raw", "error"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return fmt.Errorf("error quarantining %d %s records: %w", len(rows), r.dataset, err)
+	}
+
+	slog.Warn("Quarantined malformed records",
+		"dataset", r.dataset, "accepted", r.accepted, "rejected", len(r.rejected),
+		"first_error", r.rejected[0].err)
+	return nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrQuotaReserved is returned to non-critical work once only the reserved
+// share of the monthly quota is left.
+var ErrQuotaReserved = errors.New("remaining AviationStack quota is reserved for critical jobs")
+
+// QuotaTracker counts AviationStack requests per access key and billing
+// month in the api_quota table and hands out the key with the most requests
+// left.
+type QuotaTracker struct {
+	conn       *pgxpool.Pool
+	keys       []string
+	limit      int
+	reserve    int
+	billingDay int
+}
+
+// KeyUsage is the usage of one key in the current billing period. Keys are
+// identified by a hash and shown by their last characters only.
+type KeyUsage struct {
+	KeyID     string
+	Hint      string
+	Requests  int
+	Remaining int
+	Exhausted bool
+}
+
+type QuotaUsage struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Limit       int
+	Reserve     int
+	Requests    int
+	Remaining   int
+	Keys        []KeyUsage
+}
+
+func NewQuotaTracker(conn *pgxpool.Pool, cfg *config.AviationStackConfig) *QuotaTracker {
+	return &QuotaTracker{
+		conn:       conn,
+		keys:       cfg.AccessKeys,
+		limit:      cfg.MonthlyQuota,
+		reserve:    cfg.MonthlyQuota * cfg.QuotaReservePercent / 100,
+		billingDay: cfg.BillingDay,
+	}
+}
+
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func keyHint(key string) string {
+	if len(key) <= 4 {
+		return "…"
+	}
+	return "…" + key[len(key)-4:]
+}
+
+// period returns the start and end of the billing month containing now.
+func (q *QuotaTracker) period(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), q.billingDay, 0, 0, 0, 0, time.UTC)
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Usage returns the requests made with every configured key this period.
+func (q *QuotaTracker) Usage(ctx context.Context) (QuotaUsage, error) {
+	start, end := q.period(time.Now())
+	usage := QuotaUsage{PeriodStart: start, PeriodEnd: end, Reserve: q.reserve * len(q.keys)}
+
+	rows, _ := q.conn.Query(ctx, `select key_id, requests, exhausted from api_quota where period = $1`, start)
+	var id string
+	var requests int
+	var exhausted bool
+	counted := make(map[string]KeyUsage)
+	if _, err := pgx.ForEachRow(rows, []any{&id, &requests, &exhausted}, func() error {
+		counted[id] = KeyUsage{Requests: requests, Exhausted: exhausted}
+		return nil
+	}); err != nil {
+		return QuotaUsage{}, err
+	}
+
+	for _, key := range q.keys {
+		k := counted[keyID(key)]
+		k.KeyID, k.Hint = keyID(key), keyHint(key)
+		if k.Remaining = q.limit - k.Requests; k.Remaining < 0 || k.Exhausted {
+			k.Remaining = 0
+		}
+
+		usage.Limit += q.limit
+		usage.Requests += k.Requests
+		usage.Remaining += k.Remaining
+		usage.Keys = append(usage.Keys, k)
+	}
+	return usage, nil
+}
+
+// Allow reports whether a job may spend requests now: critical jobs while
+// any quota is left, other jobs only while more than the reserve is left.
+func (q *QuotaTracker) Allow(ctx context.Context, critical bool) error {
+	usage, err := q.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case usage.Remaining <= 0:
+		return ErrQuotaExceeded
+	case !critical && usage.Remaining <= usage.Reserve:
+		return ErrQuotaReserved
+	}
+	return nil
+}
+
+// acquire counts a request against the key with the most requests left and
+// returns it. The count is only taken while the key is under its limit and
+// not exhausted, in the same statement that checks it, so concurrent
+// requests from any number of processes never overspend a key. A key that
+// runs out between reading the usage and counting is skipped for the next.
+func (q *QuotaTracker) acquire(ctx context.Context) (string, error) {
+	usage, err := q.Usage(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	order := make([]int, 0, len(usage.Keys))
+	for i, k := range usage.Keys {
+		if k.Remaining > 0 {
+			order = append(order, i)
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return usage.Keys[b].Remaining - usage.Keys[a].Remaining
+	})
+
+	for _, i := range order {
+		var requests int
+		err := q.conn.QueryRow(ctx, `
+			insert into api_quota (key_id, period, requests) values ($1, $2, 1)
+			on conflict (key_id, period) do update set requests = api_quota.requests + 1, updated_at = now()
+			where api_quota.requests < $3 and not api_quota.exhausted
+			returning requests
+			`, usage.Keys[i].KeyID, usage.PeriodStart, q.limit,
+		).Scan(&requests)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return q.keys[i], nil
+	}
+	return "", ErrQuotaExceeded
+}
+
+// exhaust takes key out of rotation until the next billing period, after
+// AviationStack rejected it.
+func (q *QuotaTracker) exhaust(ctx context.Context, key string) error {
+	start, _ := q.period(time.Now())
+	if _, err := q.conn.Exec(ctx, `
+		insert into api_quota (key_id, period, exhausted) values ($1, $2, true)
+		on conflict (key_id, period) do update set exhausted = true, updated_at = now()
+		`, keyID(key), start,
+	); err != nil {
+		return fmt.Errorf("error marking AviationStack key %s exhausted: %w", keyHint(key), err)
+	}
+	slog.Warn("AviationStack key taken out of rotation until the next billing period", "key", keyHint(key))
+	return nil
+}
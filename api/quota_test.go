@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		billingDay int
+		now        time.Time
+		wantStart  time.Time
+	}{
+		{
+			name:       "after the billing day",
+			billingDay: 15,
+			now:        time.Date(2024, 3, 20, 10, 0, 0, 0, time.UTC),
+			wantStart:  time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "before the billing day",
+			billingDay: 15,
+			now:        time.Date(2024, 3, 14, 23, 59, 0, 0, time.UTC),
+			wantStart:  time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "across the year",
+			billingDay: 1,
+			now:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600)),
+			wantStart:  time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &QuotaTracker{billingDay: tt.billingDay}
+			start, end := q.period(tt.now)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantStart.AddDate(0, 1, 0)) {
+				t.Errorf("got period %s to %s, want it to start %s", start, end, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestQuotaAcquire(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	// keys unique to this run, so earlier runs leave no usage behind
+	suffix := time.Now().UnixNano()
+	keys := []string{fmt.Sprintf("quota-a-%d", suffix), fmt.Sprintf("quota-b-%d", suffix)}
+	q := &QuotaTracker{conn: pool, keys: keys, limit: 5, billingDay: 1}
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	exceeded := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := q.acquire(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrQuotaExceeded):
+				exceeded++
+			case err != nil:
+				t.Error(err)
+			default:
+				counts[key]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range keys {
+		if counts[key] != 5 {
+			t.Errorf("got %d requests counted for a key, want its limit of 5", counts[key])
+		}
+	}
+	if exceeded != 20 {
+		t.Errorf("got %d requests refused, want 20", exceeded)
+	}
+
+	usage, err := q.Usage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Requests != 10 || usage.Remaining != 0 {
+		t.Errorf("got %d requests and %d remaining, want 10 and 0", usage.Requests, usage.Remaining)
+	}
+}
+
+func TestQuotaAcquireExhausted(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	suffix := time.Now().UnixNano()
+	keys := []string{fmt.Sprintf("quota-a-%d", suffix), fmt.Sprintf("quota-b-%d", suffix)}
+	q := &QuotaTracker{conn: pool, keys: keys, limit: 100, billingDay: 1}
+
+	if err := q.exhaust(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		key, err := q.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != keys[1] {
+			t.Errorf("got the exhausted key handed out")
+		}
+	}
+
+	if err := q.exhaust(ctx, keys[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.acquire(ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("got error %v with every key exhausted, want ErrQuotaExceeded", err)
+	}
+}
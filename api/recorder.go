@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	ModeLive   = "live"
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9=_.-]+`)
+
+// recordingPath maps a request to its file under dir: one directory per
+// endpoint and one file per query, without the access key.
+func recordingPath(dir string, req *http.Request) string {
+	query := req.URL.Query()
+	query.Del("access_key")
+
+	name := unsafeFileChars.ReplaceAllString(query.Encode(), "_")
+	if name == "" {
+		name = "index"
+	}
+
+	return filepath.Join(dir, path.Base(req.URL.Path), name+".json")
+}
+
+// recordingTransport forwards requests upstream and saves every successful
+// response body to dir.
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(req)
+	if err != nil || response.StatusCode >= http.StatusBadRequest {
+		return response, err
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	file := recordingPath(t.dir, req)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to save recording: %w", err)
+	}
+	slog.Debug("Recorded AviationStack response", "file", file)
+
+	return response, nil
+}
+
+// replayTransport serves responses previously saved by recordingTransport and
+// never touches the network. A missing recording is reported as a 404 so the
+// client does not retry it.
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	file := recordingPath(t.dir, req)
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		body, _ := json.Marshal(map[string]APIError{
+			"error": {Code: "recording_not_found", Message: "no recording at " + file},
+		})
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       f,
+		Request:    req,
+	}, nil
+}
+
+// withRecording wraps the transport of httpClient according to mode.
+func withRecording(httpClient *http.Client, mode, dir string) (*http.Client, error) {
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	wrapped := *httpClient
+	switch mode {
+	case ModeLive, "":
+		return httpClient, nil
+	case ModeRecord:
+		wrapped.Transport = &recordingTransport{dir: dir, next: next}
+	case ModeReplay:
+		wrapped.Transport = &replayTransport{dir: dir}
+	default:
+		return nil, fmt.Errorf("unknown AviationStack mode %q", mode)
+	}
+
+	return &wrapped, nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/FACorreiaa/go-ollama/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingPath(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "access key dropped and query sorted",
+			url:  "https://api.aviationstack.com/v1/flights?offset=100&access_key=secret&limit=100",
+			want: "flights/limit=100_offset=100.json",
+		},
+		{
+			name: "unsafe characters replaced",
+			url:  "https://api.aviationstack.com/v1/flights?dep_iata=LIS&airline_name=TAP%20Air/Portugal",
+			want: "flights/airline_name=TAP_Air_2FPortugal_dep_iata=LIS.json",
+		},
+		{
+			name: "no query",
+			url:  "https://api.aviationstack.com/v1/countries?access_key=secret",
+			want: "countries/index.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if got := recordingPath("recordings", req); got != filepath.Join("recordings", tt.want) {
+				t.Errorf("got %q, want %q", got, filepath.Join("recordings", tt.want))
+			}
+		})
+	}
+}
+
+// newModeClient returns a client for baseURL in mode, keeping recordings
+// in dir.
+func newModeClient(t *testing.T, baseURL, mode, dir string, keys ...string) *AviationStackClient {
+	t.Helper()
+	client, err := NewAviationStackClient(&config.AviationStackConfig{
+		BaseURL:        baseURL + "/v1",
+		AccessKeys:     keys,
+		UserAgent:      "go-ollama-test",
+		MaxRetries:     2,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+		Mode:           mode,
+		RecordingsDir:  dir,
+	}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRecordReplay(t *testing.T) {
+	const body = `{"pagination":{"offset":0,"limit":100,"count":1,"total":1},"data":[{"country_name":"Portugal"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	dir := t.TempDir()
+
+	recorder := newModeClient(t, server.URL, ModeRecord, dir, "secret")
+	got, err := recorder.request(context.Background(), "countries", "offset=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(got)
+	got.Close()
+	if string(data) != body {
+		t.Errorf("got recorded response %q, want the upstream body", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "countries", "offset=0.json")); err != nil {
+		t.Fatalf("recording not saved: %v", err)
+	}
+
+	// the replay client has no key and nothing to connect to
+	server.Close()
+	replayer := newModeClient(t, server.URL, ModeReplay, dir)
+	got, err = replayer.request(context.Background(), "countries", "offset=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(got)
+	got.Close()
+	if string(data) != body {
+		t.Errorf("got replayed response %q, want %q", data, body)
+	}
+}
+
+func TestRecordSkipsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(restrictedBody))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	recorder := newModeClient(t, server.URL, ModeRecord, dir, "secret")
+	if _, err := recorder.request(context.Background(), "flights"); !errors.Is(err, ErrFunctionAccessRestricted) {
+		t.Errorf("got error %v, want the upstream error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "flights")); !os.IsNotExist(err) {
+		t.Errorf("got a recording of a failed response: %v", err)
+	}
+}
+
+func TestReplayMissingRecording(t *testing.T) {
+	replayer := newModeClient(t, "http://127.0.0.1:0", ModeReplay, t.TempDir())
+
+	_, err := replayer.request(context.Background(), "airports", "offset=0")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "recording_not_found" {
+		t.Errorf("got status %d code %q, want 404 recording_not_found", apiErr.StatusCode, apiErr.Code)
+	}
+	if apiErr.retryable() {
+		t.Error("got a missing recording retryable")
+	}
+}
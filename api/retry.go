@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// Status of a queued retry.
+const (
+	RetryPending = "pending"
+	RetryDead    = "dead"
+)
+
+// ErrRetryNotFound is returned for a retry id that is not queued.
+var ErrRetryNotFound = errors.New("retry not found")
+
+// JobRetry is a job whose last run failed, waiting in the job_retry table
+// to be run again, or dead once it ran out of attempts.
+type JobRetry struct {
+	ID            string    `json:"id"`
+	Job           string    `json:"job"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RetryQueue keeps failed jobs in Postgres and schedules them again with
+// exponential backoff. A job that failed maxAttempts times in a row is dead
+// and stays so until an operator retries or discards it.
+type RetryQueue struct {
+	conn        *pgxpool.Pool
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func NewRetryQueue(conn *pgxpool.Pool, cfg *config.JobsConfig) *RetryQueue {
+	return &RetryQueue{
+		conn:        conn,
+		maxAttempts: cfg.RetryMaxAttempts,
+		baseDelay:   cfg.RetryBaseDelay,
+		maxDelay:    cfg.RetryMaxDelay,
+	}
+}
+
+// backoff returns the delay before the attempt after the given number of
+// failed ones: baseDelay doubled per failure, at most maxDelay. Fewer than
+// one failure counts as one.
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+	attempts = max(attempts, 1)
+	delay := q.maxDelay
+	if attempts <= 32 {
+		if d := q.baseDelay << (attempts - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// failed queues job after a failed run, or counts the attempt when it is
+// already queued, and declares it dead once it is out of attempts. A dead
+// job stays dead, with the new error, until it is retried or succeeds.
+func (q *RetryQueue) failed(ctx context.Context, job string, runErr error) error {
+	tx, err := q.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id, status string
+	var attempts int
+	if err := tx.QueryRow(ctx, `
+		insert into job_retry (job, status, attempts, last_error) values ($1, $2, 1, $3)
+		on conflict (job) do update
+		set attempts = job_retry.attempts + 1, last_error = excluded.last_error, updated_at = now()
+		returning id::text, status, attempts`,
+		job, RetryPending, runErr.Error(),
+	).Scan(&id, &status, &attempts); err != nil {
+		return err
+	}
+
+	switch {
+	case status == RetryDead:
+		slog.Error("Dead-lettered job failed again", "job", job, "attempts", attempts, "error", runErr)
+	case attempts >= q.maxAttempts:
+		if _, err := tx.Exec(ctx, `update job_retry set status = $2 where id = $1`, id, RetryDead); err != nil {
+			return err
+		}
+		slog.Error("Job out of retries", "job", job, "attempts", attempts, "error", runErr)
+	default:
+		delay := q.backoff(attempts)
+		if _, err := tx.Exec(ctx,
+			`update job_retry set next_attempt_at = now() + $2::interval where id = $1`, id, delay,
+		); err != nil {
+			return err
+		}
+		slog.Warn("Job queued for retry", "job", job, "attempts", attempts, "delay", delay)
+	}
+	return tx.Commit(ctx)
+}
+
+// succeeded removes the retry of job, pending or dead, if any.
+func (q *RetryQueue) succeeded(ctx context.Context, job string) error {
+	_, err := q.conn.Exec(ctx, `delete from job_retry where job = $1`, job)
+	return err
+}
+
+// due returns the jobs whose next attempt is due.
+func (q *RetryQueue) due(ctx context.Context) ([]string, error) {
+	rows, err := q.conn.Query(ctx, `
+		select job from job_retry where status = $1 and next_attempt_at <= now() order by next_attempt_at`,
+		RetryPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// List returns the queued retries, only those with status when it is not
+// empty.
+func (q *RetryQueue) List(ctx context.Context, status string) ([]JobRetry, error) {
+	rows, err := q.conn.Query(ctx, `
+		select id::text, job, status, attempts, next_attempt_at, coalesce(last_error, ''), created_at, updated_at
+		from job_retry
+		where $1 = '' or status = $1
+		order by status desc, next_attempt_at`,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (JobRetry, error) {
+		var retry JobRetry
+		err := row.Scan(
+			&retry.ID, &retry.Job, &retry.Status, &retry.Attempts, &retry.NextAttemptAt,
+			&retry.LastError, &retry.CreatedAt, &retry.UpdatedAt,
+		)
+		return retry, err
+	})
+}
+
+// Retry makes the retry with id pending and due now, with a fresh set of
+// attempts.
+func (q *RetryQueue) Retry(ctx context.Context, id string) error {
+	tag, err := q.conn.Exec(ctx, `
+		update job_retry set status = $2, attempts = 0, next_attempt_at = now(), updated_at = now()
+		where id::text = $1`,
+		id, RetryPending,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrRetryNotFound, id)
+	}
+	return nil
+}
+
+// Discard removes the retry with id from the queue.
+func (q *RetryQueue) Discard(ctx context.Context, id string) error {
+	tag, err := q.conn.Exec(ctx, `delete from job_retry where id::text = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrRetryNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	q := &RetryQueue{baseDelay: time.Minute, maxDelay: time.Hour}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: -1, want: time.Minute},
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 40, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempts); got != tt.want {
+			t.Errorf("got backoff %s after %d attempts, want %s", got, tt.attempts, tt.want)
+		}
+	}
+}
+
+// testRetryQueue returns a queue over the test database and a job name no
+// earlier run has queued.
+func testRetryQueue(t *testing.T) (*RetryQueue, string) {
+	t.Helper()
+	q := &RetryQueue{conn: testPool(t), maxAttempts: 3, baseDelay: time.Minute, maxDelay: time.Hour}
+	job := fmt.Sprintf("retry-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { q.succeeded(context.Background(), job) })
+	return q, job
+}
+
+// findRetry returns the queued retry of job, if any.
+func findRetry(t *testing.T, q *RetryQueue, job string) (JobRetry, bool) {
+	t.Helper()
+	retries, err := q.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(retries, func(r JobRetry) bool { return r.Job == job })
+	if i < 0 {
+		return JobRetry{}, false
+	}
+	return retries[i], true
+}
+
+// isDue reports whether the next attempt of job is due.
+func isDue(t *testing.T, q *RetryQueue, job string) bool {
+	t.Helper()
+	jobs, err := q.due(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slices.Contains(jobs, job)
+}
+
+func TestRetryQueueDeadLetter(t *testing.T) {
+	q, job := testRetryQueue(t)
+	ctx := context.Background()
+
+	for attempt := 1; attempt < q.maxAttempts; attempt++ {
+		if err := q.failed(ctx, job, errors.New("upstream down")); err != nil {
+			t.Fatal(err)
+		}
+		retry, ok := findRetry(t, q, job)
+		if !ok || retry.Status != RetryPending || retry.Attempts != attempt {
+			t.Fatalf("got retry %+v after %d failures, want it pending", retry, attempt)
+		}
+		if until := time.Until(retry.NextAttemptAt); until < 30*time.Second {
+			t.Errorf("got next attempt in %s, want it backed off", until)
+		}
+		if isDue(t, q, job) {
+			t.Error("got a backed off retry due")
+		}
+	}
+
+	if err := q.failed(ctx, job, errors.New("still down")); err != nil {
+		t.Fatal(err)
+	}
+	retry, _ := findRetry(t, q, job)
+	if retry.Status != RetryDead || retry.Attempts != q.maxAttempts {
+		t.Errorf("got retry %+v, want it dead after %d attempts", retry, q.maxAttempts)
+	}
+
+	// a dead job failing again stays dead with the new error
+	if err := q.failed(ctx, job, errors.New("gone")); err != nil {
+		t.Fatal(err)
+	}
+	if retry, _ := findRetry(t, q, job); retry.Status != RetryDead || retry.LastError != "gone" {
+		t.Errorf("got retry %+v, want it dead with the last error", retry)
+	}
+
+	if err := q.succeeded(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if retry, ok := findRetry(t, q, job); ok {
+		t.Errorf("got retry %+v, want it cleared by a successful run", retry)
+	}
+}
+
+func TestRetryQueueSucceededClearsPending(t *testing.T) {
+	q, job := testRetryQueue(t)
+	ctx := context.Background()
+
+	if err := q.failed(ctx, job, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.succeeded(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if retry, ok := findRetry(t, q, job); ok {
+		t.Errorf("got retry %+v, want it cleared by a successful run", retry)
+	}
+
+	// the next failure starts counting again
+	if err := q.failed(ctx, job, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+	if retry, _ := findRetry(t, q, job); retry.Attempts != 1 {
+		t.Errorf("got %d attempts, want 1", retry.Attempts)
+	}
+}
+
+func TestRetryQueueRetryDiscard(t *testing.T) {
+	q, job := testRetryQueue(t)
+	ctx := context.Background()
+
+	for attempt := 0; attempt < q.maxAttempts; attempt++ {
+		if err := q.failed(ctx, job, errors.New("upstream down")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dead, _ := findRetry(t, q, job)
+	if dead.Status != RetryDead {
+		t.Fatalf("got retry %+v, want it dead", dead)
+	}
+
+	if err := q.Retry(ctx, dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	if retry, _ := findRetry(t, q, job); retry.Status != RetryPending || retry.Attempts != 0 {
+		t.Errorf("got retry %+v, want it pending with its attempts reset", retry)
+	}
+	if !isDue(t, q, job) {
+		t.Error("got a retried job not due")
+	}
+	// a failure after a retry is backed off like the first one
+	if err := q.failed(ctx, job, errors.New("upstream down")); err != nil {
+		t.Fatal(err)
+	}
+	if retry, _ := findRetry(t, q, job); retry.Status != RetryPending || retry.Attempts != 1 {
+		t.Errorf("got retry %+v, want it pending after one attempt", retry)
+	}
+
+	if err := q.Discard(ctx, dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	if retry, ok := findRetry(t, q, job); ok {
+		t.Errorf("got retry %+v, want it discarded", retry)
+	}
+
+	// ids are compared as text, so a malformed one is just not found
+	for _, id := range []string{dead.ID, "not-a-uuid", ""} {
+		if err := q.Retry(ctx, id); !errors.Is(err, ErrRetryNotFound) {
+			t.Errorf("got Retry error %v for id %q, want ErrRetryNotFound", err, id)
+		}
+		if err := q.Discard(ctx, id); !errors.Is(err, ErrRetryNotFound) {
+			t.Errorf("got Discard error %v for id %q, want ErrRetryNotFound", err, id)
+		}
+	}
+}
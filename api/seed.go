@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/config"
+	"golang.org/x/sync/errgroup"
+	"log/slog"
+	"time"
+)
+
+// SeedStep is one dataset seeded at startup.
+type SeedStep struct {
+	Name string
+	// After lists the datasets that have to be seeded before this one
+	After []string
+	// Critical datasets are needed before the server starts, the others are
+	// seeded in the background
+	Critical bool
+	// Datasets are the seed checkpoints Run completes, Name when empty
+	Datasets []string
+	Run      func(ctx context.Context) error
+}
+
+// seedLockPollInterval is how often a dataset another instance is seeding is
+// checked on.
+const seedLockPollInterval = 5 * time.Second
+
+// ErrSeedSkipped is the error of a dataset not seeded because one it is
+// seeded after failed.
+var ErrSeedSkipped = errors.New("dataset not seeded")
+
+// Seeder seeds datasets concurrently, up to a limit, each one once the
+// datasets it depends on are done. Every seed is recorded in the ledger and
+// locked, so instances starting together seed each dataset once. A dataset
+// whose checkpoints all completed is skipped without recording a run.
+type Seeder struct {
+	ledger      *JobLedger
+	locker      *JobLocker
+	checkpoints *SeedCheckpoints
+	timeout     time.Duration
+	limit       int
+	// steps is sorted so a step comes after the ones it depends on
+	steps []SeedStep
+
+	results map[string]*seedResult
+	all     chan struct{}
+}
+
+// seedResult is the outcome of one step, err is set before done is closed.
+type seedResult struct {
+	done chan struct{}
+	err  error
+}
+
+// NewSeeder returns a Seeder for steps. It fails when a step depends on a
+// dataset that is not in steps or the dependencies form a cycle.
+func NewSeeder(
+	ledger *JobLedger,
+	locker *JobLocker,
+	checkpoints *SeedCheckpoints,
+	cfg *config.JobsConfig,
+	steps []SeedStep,
+) (*Seeder, error) {
+	sorted, err := sortSeedSteps(steps)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Seeder{
+		ledger:      ledger,
+		locker:      locker,
+		checkpoints: checkpoints,
+		timeout:     cfg.SeedTimeout,
+		limit:       cfg.SeedConcurrency,
+		steps:       sorted,
+		results:     make(map[string]*seedResult, len(steps)),
+		all:         make(chan struct{}),
+	}
+	for _, step := range steps {
+		s.results[step.Name] = &seedResult{done: make(chan struct{})}
+	}
+	return s, nil
+}
+
+// sortSeedSteps orders steps so that every step comes after the ones it
+// depends on, keeping the given order otherwise.
+func sortSeedSteps(steps []SeedStep) ([]SeedStep, error) {
+	byName := make(map[string]SeedStep, len(steps))
+	for _, step := range steps {
+		byName[step.Name] = step
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(steps))
+	sorted := make([]SeedStep, 0, len(steps))
+
+	var visit func(step SeedStep) error
+	visit = func(step SeedStep) error {
+		switch state[step.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("seed dependency cycle at %s", step.Name)
+		}
+		state[step.Name] = visiting
+		for _, name := range step.After {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%s is seeded after unknown dataset %s", step.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[step.Name] = visited
+		sorted = append(sorted, step)
+		return nil
+	}
+
+	for _, step := range steps {
+		if err := visit(step); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// Start seeds the datasets in the background until ctx is done. Use
+// WaitCritical to wait for the critical ones and Wait for all of them.
+func (s *Seeder) Start(ctx context.Context) {
+	var g errgroup.Group
+	g.SetLimit(s.limit)
+
+	go func() {
+		defer close(s.all)
+		// Steps start in dependency order, so the steps one waits for
+		// already hold a slot and the limit cannot deadlock
+		for _, step := range s.steps {
+			step := step
+			g.Go(func() error {
+				result := s.results[step.Name]
+				result.err = s.seed(ctx, step)
+				close(result.done)
+				return nil
+			})
+		}
+		_ = g.Wait()
+	}()
+}
+
+// seed waits for the datasets step depends on and then seeds it, unless
+// one of them failed. When another instance is seeding it, seed waits for
+// that instance to finish first.
+func (s *Seeder) seed(ctx context.Context, step SeedStep) error {
+	for _, name := range step.After {
+		dep := s.results[name]
+		select {
+		case <-dep.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if dep.err != nil {
+			slog.Warn("Skipping dataset, a dataset it depends on was not seeded",
+				"dataset", step.Name, "dependency", name)
+			return fmt.Errorf("%w %s: %s failed", ErrSeedSkipped, step.Name, name)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := s.runLocked(ctx, step)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		slog.Info("Interrupted while seeding", "dataset", step.Name, "error", err)
+	case err != nil:
+		slog.Error("Error seeding dataset", "dataset", step.Name, "critical", step.Critical, "error", err)
+	}
+	return err
+}
+
+// runLocked runs step holding its lock, unless its checkpoints say it is
+// already seeded. Another instance starting at the same time may hold it,
+// its seed is waited for by taking the lock once it is released: step then
+// finds the dataset seeded, or seeds it itself if that instance failed.
+func (s *Seeder) runLocked(ctx context.Context, step SeedStep) error {
+	waiting := false
+	for {
+		err := s.locker.Do(ctx, "seed:"+step.Name, func(ctx context.Context) error {
+			seeded, err := s.seeded(ctx, step)
+			if err != nil {
+				return err
+			}
+			if seeded {
+				slog.Info("Dataset already seeded, skipping", "dataset", step.Name)
+				return nil
+			}
+			return s.ledger.Run(ctx, step.Name, TriggerStartup, func(ctx context.Context) (SyncResult, error) {
+				return SyncResult{}, step.Run(ctx)
+			})
+		})
+		if !errors.Is(err, ErrLockHeld) {
+			return err
+		}
+		if !waiting {
+			slog.Info("Dataset being seeded by another instance, waiting", "dataset", step.Name)
+			waiting = true
+		}
+
+		select {
+		case <-time.After(seedLockPollInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for another instance to seed %s: %w", step.Name, ctx.Err())
+		}
+	}
+}
+
+// seeded reports whether every checkpoint of step completed.
+func (s *Seeder) seeded(ctx context.Context, step SeedStep) (bool, error) {
+	datasets := step.Datasets
+	if len(datasets) == 0 {
+		datasets = []string{step.Name}
+	}
+	for _, dataset := range datasets {
+		checkpoint, err := s.checkpoints.Get(ctx, dataset)
+		if err != nil {
+			return false, fmt.Errorf("error reading the %s seed checkpoint: %w", dataset, err)
+		}
+		if !checkpoint.Completed() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// WaitCritical waits for the critical datasets and returns their errors.
+func (s *Seeder) WaitCritical() error {
+	var errs []error
+	for _, step := range s.steps {
+		if !step.Critical {
+			continue
+		}
+		result := s.results[step.Name]
+		<-result.done
+		if err := result.err; err != nil {
+			errs = append(errs, fmt.Errorf("seeding %s: %w", step.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Wait waits for every dataset to be seeded, or returns ctx.Err() when ctx
+// is done first. Failures of non-critical datasets are only logged.
+func (s *Seeder) Wait(ctx context.Context) error {
+	select {
+	case <-s.all:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
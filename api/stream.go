@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"io"
+)
+
+// recordStream decodes the "data" array of an AviationStack response one
+// record at a time, so records go straight from the HTTP body into CopyFrom
+// and memory stays flat whatever the page size. A record that is valid JSON
+// but does not decode into T is handed to reject and skipped; without reject
+// it fails the stream.
+type recordStream[T any] struct {
+	dec        *json.Decoder
+	reject     func(json.RawMessage, error)
+	pagination structs.Pagination
+	current    T
+	accepted   int
+	inData     bool
+	done       bool
+	err        error
+}
+
+func newRecordStream[T any](r io.Reader, reject func(json.RawMessage, error)) *recordStream[T] {
+	return &recordStream[T]{dec: json.NewDecoder(r), reject: reject}
+}
+
+func (s *recordStream[T]) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+
+	if !s.inData {
+		if err := s.seekData(); err != nil {
+			s.err = err
+			return false
+		}
+		if !s.inData {
+			s.done = true
+			return false
+		}
+	}
+
+	for s.dec.More() {
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			s.err = fmt.Errorf("error decoding record: %w", err)
+			return false
+		}
+
+		var record T
+		if err := json.Unmarshal(raw, &record); err != nil {
+			if s.reject == nil {
+				s.err = fmt.Errorf("error decoding record: %w", err)
+				return false
+			}
+			s.reject(raw, err)
+			continue
+		}
+		s.current = record
+		s.accepted++
+		return true
+	}
+
+	// Consume the closing ']' and whatever follows the data array,
+	// the pagination object may come after it.
+	if _, err := s.dec.Token(); err != nil {
+		s.err = fmt.Errorf("error reading end of data array: %w", err)
+		return false
+	}
+	if err := s.readObject(); err != nil {
+		s.err = err
+	}
+	s.done = true
+	return false
+}
+
+func (s *recordStream[T]) Record() T {
+	return s.current
+}
+
+func (s *recordStream[T]) Err() error {
+	return s.err
+}
+
+// Accepted returns how many records have been decoded so far.
+func (s *recordStream[T]) Accepted() int {
+	return s.accepted
+}
+
+// Pagination is only complete once the stream has been fully consumed.
+func (s *recordStream[T]) Pagination() structs.Pagination {
+	return s.pagination
+}
+
+// seekData reads the top-level object up to the start of the data array.
+func (s *recordStream[T]) seekData() error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return errors.New("error reading response: expected a JSON object")
+	}
+
+	return s.readObject()
+}
+
+// readObject walks the keys of the top-level object, decoding pagination and
+// skipping unknown keys. It stops when it enters the data array or reaches
+// the end of the object.
+func (s *recordStream[T]) readObject() error {
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return fmt.Errorf("error reading response: %w", err)
+		}
+
+		switch tok {
+		case "pagination":
+			if err := s.dec.Decode(&s.pagination); err != nil {
+				return fmt.Errorf("error decoding pagination: %w", err)
+			}
+		case "data":
+			if s.inData {
+				return errors.New("error reading response: duplicate data key")
+			}
+			tok, err := s.dec.Token()
+			if err != nil {
+				return fmt.Errorf("error reading data array: %w", err)
+			}
+			if tok == nil {
+				continue
+			}
+			if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+				return errors.New("error reading response: data is not an array")
+			}
+			s.inData = true
+			return nil
+		default:
+			var skip json.RawMessage
+			if err := s.dec.Decode(&skip); err != nil {
+				return fmt.Errorf("error reading response: %w", err)
+			}
+		}
+	}
+
+	// Closing '}'
+	_, err := s.dec.Token()
+	return err
+}
+
+// copySource adapts a RecordSource to pgx.CopyFromSource.
+type copySource[T any] struct {
+	src RecordSource[T]
+	row func(T) []any
+}
+
+func (c *copySource[T]) Next() bool {
+	return c.src.Next()
+}
+
+func (c *copySource[T]) Values() ([]any, error) {
+	return c.row(c.src.Record()), nil
+}
+
+func (c *copySource[T]) Err() error {
+	return c.src.Err()
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"strings"
+	"testing"
+)
+
+type streamRecord struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// drain reads every record of a stream over body.
+func drain(body string, reject func(json.RawMessage, error)) ([]streamRecord, *recordStream[streamRecord]) {
+	stream := newRecordStream[streamRecord](strings.NewReader(body), reject)
+
+	var records []streamRecord
+	for stream.Next() {
+		records = append(records, stream.Record())
+	}
+	return records, stream
+}
+
+func TestRecordStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		wantIDs        []int
+		wantPagination structs.Pagination
+	}{
+		{
+			name:           "pagination first",
+			body:           `{"pagination":{"offset":0,"limit":100,"count":2,"total":2},"data":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}`,
+			wantIDs:        []int{1, 2},
+			wantPagination: structs.Pagination{Limit: 100, Count: 2, Total: 2},
+		},
+		{
+			name:           "pagination last",
+			body:           `{"data":[{"id":3,"name":"c"}],"extra":{"nested":[1,2]},"pagination":{"offset":100,"limit":100,"count":1,"total":101}}`,
+			wantIDs:        []int{3},
+			wantPagination: structs.Pagination{Offset: 100, Limit: 100, Count: 1, Total: 101},
+		},
+		{
+			name:           "empty data",
+			body:           `{"pagination":{"offset":0,"limit":100,"count":0,"total":0},"data":[]}`,
+			wantPagination: structs.Pagination{Limit: 100},
+		},
+		{
+			name: "null data",
+			body: `{"data":null}`,
+		},
+		{
+			name:           "no data",
+			body:           `{"pagination":{"total":5}}`,
+			wantPagination: structs.Pagination{Total: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, stream := drain(tt.body, nil)
+			if err := stream.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.wantIDs) {
+				t.Fatalf("got %d records, want %d", len(records), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if records[i].ID != id {
+					t.Errorf("record %d has id %d, want %d", i, records[i].ID, id)
+				}
+			}
+			if stream.Accepted() != len(tt.wantIDs) {
+				t.Errorf("got %d accepted, want %d", stream.Accepted(), len(tt.wantIDs))
+			}
+			if stream.Pagination() != tt.wantPagination {
+				t.Errorf("got pagination %+v, want %+v", stream.Pagination(), tt.wantPagination)
+			}
+		})
+	}
+}
+
+func TestRecordStreamErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "not an object", body: `[{"id":1}]`},
+		{name: "data not an array", body: `{"data":{"id":1}}`},
+		{name: "truncated", body: `{"data":[{"id":1},{"id":`},
+		{name: "duplicate data", body: `{"data":[],"data":[]}`},
+		{name: "malformed record without reject", body: `{"data":[{"id":"one"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, stream := drain(tt.body, nil); stream.Err() == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestRecordStreamReject(t *testing.T) {
+	body := `{"data":[{"id":1},{"id":"two"},{"id":3},{"id":4,"name":5}],"pagination":{"total":4}}`
+
+	var rejected []string
+	records, stream := drain(body, func(raw json.RawMessage, err error) {
+		if err == nil {
+			t.Error("rejected a record without an error")
+		}
+		rejected = append(rejected, string(raw))
+	})
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[0].ID != 1 || records[1].ID != 3 {
+		t.Errorf("got records %+v, want ids 1 and 3", records)
+	}
+	if want := []string{`{"id":"two"}`, `{"id":4,"name":5}`}; strings.Join(rejected, " ") != strings.Join(want, " ") {
+		t.Errorf("got rejected %q, want %q", rejected, want)
+	}
+	if stream.Accepted() != 2 {
+		t.Errorf("got %d accepted, want 2", stream.Accepted())
+	}
+	if stream.Pagination().Total != 4 {
+		t.Errorf("got total %d, want the pagination after the data", stream.Pagination().Total)
+	}
+}
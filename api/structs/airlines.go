@@ -79,19 +79,23 @@ type Tax struct {
 }
 
 type TaxApiData struct {
-	Data []Tax `json:"data"`
+	Pagination Pagination `json:"pagination"`
+	Data       []Tax      `json:"data"`
 }
 
 type AircraftApiData struct {
-	Data []Aircraft `json:"data"`
+	Pagination Pagination `json:"pagination"`
+	Data       []Aircraft `json:"data"`
 }
 
 type AirlineApiData struct {
-	Data []Airline `json:"data"`
+	Pagination Pagination `json:"pagination"`
+	Data       []Airline  `json:"data"`
 }
 
 type AirplaneApiData struct {
-	Data []Airplane `json:"data"`
+	Pagination Pagination `json:"pagination"`
+	Data       []Airplane `json:"data"`
 }
 
 type CustomTime struct {
@@ -8,6 +8,7 @@ type Airport struct {
 	IataCode     string      `json:"iata_code"`
 	CityIataCode string      `json:"city_iata_code"`
 	IcaoCode     string      `json:"icao_code"`
+	Ident        string      `json:"ident,omitempty"`
 	CountryISO2  string      ` json:"country_iso2"`
 	GeonameID    string      `json:"geoname_id,omitempty"`
 	Latitude     float64     `json:"latitude,string,omitempty"`
@@ -17,6 +18,11 @@ type Airport struct {
 	PhoneNumber  interface{} ` json:"phone_number"`
 	Timezone     string      ` json:"timezone"`
 	CreatedAt    CustomTime  `db:"created_at" json:"created_at"`
+
+	// Loaded from the OurAirports tables, matched on Ident
+	Runways     []Runway           `json:"runways,omitempty"`
+	Frequencies []AirportFrequency `json:"frequencies,omitempty"`
+	Navaids     []Navaid           `json:"navaids,omitempty"`
 }
 
 type AirportApiData struct {
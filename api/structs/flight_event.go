@@ -0,0 +1,35 @@
+package structs
+
+import "time"
+
+type FlightEventType string
+
+const (
+	StatusChanged   FlightEventType = "status"
+	DelayChanged    FlightEventType = "delay"
+	GateChanged     FlightEventType = "gate"
+	TerminalChanged FlightEventType = "terminal"
+	RunwayTimeSet   FlightEventType = "runway"
+	Diversion       FlightEventType = "diversion"
+)
+
+// FlightEvent is a change to a flight seen between two syncs. Field is the
+// flights column that changed; values are nil when unset.
+type FlightEvent struct {
+	Type     FlightEventType `json:"type"`
+	Field    string          `json:"field"`
+	OldValue *string         `json:"old_value"`
+	NewValue *string         `json:"new_value"`
+	At       time.Time       `json:"at"`
+}
+
+// FlightTimeline is a flight with the events recorded for it, oldest first.
+type FlightTimeline struct {
+	ID            string        `json:"id"`
+	FlightIata    string        `json:"flight_iata"`
+	FlightDate    string        `json:"flight_date"`
+	DepartureIata string        `json:"departure_iata"`
+	ArrivalIata   string        `json:"arrival_iata"`
+	FlightStatus  FlightStatus  `json:"flight_status"`
+	Events        []FlightEvent `json:"events"`
+}
@@ -72,19 +72,23 @@ type LiveFlights struct {
 		AircraftIcao         string `json:"icao"`
 		AircraftIcao24       string `json:"icao24"`
 	} `json:"aircraft,omitempty"`
-	Live struct {
-		LiveUpdated         string  `json:"updated"`
-		LiveLatitude        float32 `json:"latitude,omitempty"`
-		LiveLongitude       float32 `json:"longitude,omitempty"`
-		LiveAltitude        int     `json:"altitude"`
-		LiveDirection       float32 `json:"direction"`
-		LiveSpeedHorizontal int     `json:"speed_horizontal"`
-		LiveSpeedVertical   int     `json:"speed_vertical"`
-		LiveIsGround        bool    `json:"is_ground"`
-	} `json:"live,omitempty"`
+	Live      Live       `json:"live,omitempty"`
 	CreatedAt CustomTime `json:"created_at"`
 }
 
+// Live is the last known position of a flight, in the units AviationStack
+// uses: altitude in metres, speeds in km/h.
+type Live struct {
+	LiveUpdated         string  `json:"updated"`
+	LiveLatitude        float32 `json:"latitude,omitempty"`
+	LiveLongitude       float32 `json:"longitude,omitempty"`
+	LiveAltitude        int     `json:"altitude"`
+	LiveDirection       float32 `json:"direction"`
+	LiveSpeedHorizontal int     `json:"speed_horizontal"`
+	LiveSpeedVertical   int     `json:"speed_vertical"`
+	LiveIsGround        bool    `json:"is_ground"`
+}
+
 type FlightApiData struct {
 	Pagination Pagination    `json:"pagination"`
 	Data       []LiveFlights `json:"data"`
@@ -0,0 +1,57 @@
+package structs
+
+// OurAirports runways, radio frequencies and navaids, linked to an airport by its OurAirports ident
+
+type Runway struct {
+	ID                     string     `json:"id"`
+	RunwayId               int        `json:"runway_id"`
+	AirportIdent           string     `json:"airport_ident"`
+	LengthFt               *int       `json:"length_ft"`
+	WidthFt                *int       `json:"width_ft"`
+	Surface                string     `json:"surface"`
+	Lighted                bool       `json:"lighted"`
+	Closed                 bool       `json:"closed"`
+	LeIdent                string     `json:"le_ident"`
+	LeLatitude             *float64   `json:"le_latitude"`
+	LeLongitude            *float64   `json:"le_longitude"`
+	LeElevationFt          *int       `json:"le_elevation_ft"`
+	LeHeadingDeg           *float64   `json:"le_heading_deg"`
+	LeDisplacedThresholdFt *int       `json:"le_displaced_threshold_ft"`
+	HeIdent                string     `json:"he_ident"`
+	HeLatitude             *float64   `json:"he_latitude"`
+	HeLongitude            *float64   `json:"he_longitude"`
+	HeElevationFt          *int       `json:"he_elevation_ft"`
+	HeHeadingDeg           *float64   `json:"he_heading_deg"`
+	HeDisplacedThresholdFt *int       `json:"he_displaced_threshold_ft"`
+	CreatedAt              CustomTime `db:"created_at" json:"created_at"`
+}
+
+type AirportFrequency struct {
+	ID           string     `json:"id"`
+	FrequencyId  int        `json:"frequency_id"`
+	AirportIdent string     `json:"airport_ident"`
+	Type         string     `json:"type"`
+	Description  string     `json:"description"`
+	FrequencyMhz *float64   `json:"frequency_mhz"`
+	CreatedAt    CustomTime `db:"created_at" json:"created_at"`
+}
+
+type Navaid struct {
+	ID                   string     `json:"id"`
+	NavaidId             int        `json:"navaid_id"`
+	Ident                string     `json:"ident"`
+	NavaidName           string     `json:"navaid_name"`
+	Type                 string     `json:"type"`
+	FrequencyKhz         *int       `json:"frequency_khz"`
+	Latitude             *float64   `json:"latitude"`
+	Longitude            *float64   `json:"longitude"`
+	ElevationFt          *int       `json:"elevation_ft"`
+	IsoCountry           string     `json:"iso_country"`
+	DmeFrequencyKhz      *int       `json:"dme_frequency_khz"`
+	DmeChannel           string     `json:"dme_channel"`
+	MagneticVariationDeg *float64   `json:"magnetic_variation_deg"`
+	UsageType            string     `json:"usage_type"`
+	Power                string     `json:"power"`
+	AssociatedAirport    string     `json:"associated_airport"`
+	CreatedAt            CustomTime `db:"created_at" json:"created_at"`
+}
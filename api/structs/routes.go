@@ -0,0 +1,22 @@
+package structs
+
+// routes
+
+type Route struct {
+	ID                 string     `json:"id"`
+	AirlineCode        string     `json:"airline_code"`
+	AirlineId          int        `json:"airline_id,string"`
+	DepartureIata      string     `json:"departure_iata"`
+	DepartureAirportId int        `json:"departure_airport_id,string"`
+	ArrivalIata        string     `json:"arrival_iata"`
+	ArrivalAirportId   int        `json:"arrival_airport_id,string"`
+	Codeshare          bool       `json:"codeshare"`
+	Stops              int        `json:"stops,string"`
+	Equipment          string     `json:"equipment"`
+	CreatedAt          CustomTime `db:"created_at" json:"created_at"`
+}
+
+type RouteApiData struct {
+	Pagination Pagination `json:"pagination"`
+	Data       []Route    `json:"data"`
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+)
+
+// Syncer keeps a table in step with an AviationStack endpoint. A dataset is
+// synced by registering a Syncer in referenceSyncers; its schedule comes
+// from config.JobsConfig.
+type Syncer[T any] struct {
+	// Name is the job name, as used in logs and config
+	Name     string
+	Endpoint string
+	Table    string
+	// KeyColumn holds the upstream id, it must be unique in Table
+	KeyColumn string
+	Columns   []string
+	Row       func(T) []any
+}
+
+// dataSyncer is a sync job: a Syncer of any record type or a syncFunc.
+type dataSyncer interface {
+	name() string
+	// critical jobs may spend the quota reserve
+	critical() bool
+	sync(ctx context.Context, conn *pgxpool.Pool, client *AviationStackClient) (SyncResult, error)
+}
+
+func (s Syncer[T]) name() string {
+	return s.Name
+}
+
+func (s Syncer[T]) critical() bool {
+	return false
+}
+
+// syncFunc is a sync job that does not fit Syncer.
+type syncFunc struct {
+	job        string
+	isCritical bool
+	fn         func(ctx context.Context) (SyncResult, error)
+}
+
+func (f syncFunc) name() string {
+	return f.job
+}
+
+func (f syncFunc) critical() bool {
+	return f.isCritical
+}
+
+func (f syncFunc) sync(ctx context.Context, _ *pgxpool.Pool, _ *AviationStackClient) (SyncResult, error) {
+	return f.fn(ctx)
+}
+
+// sync fetches Endpoint and upserts it into Table in one transaction.
+// Records repeating an id are dropped, the last one wins. It is skipped when
+// the cached response has not changed since the last sync of Table. Rows are
+// only soft-deleted when no record was quarantined, a rejected record may
+// still be upstream.
+func (s Syncer[T]) sync(ctx context.Context, conn *pgxpool.Pool, client *AviationStackClient) (SyncResult, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("error starting %s sync: %w", s.Table, err)
+	}
+	defer tx.Rollback(ctx)
+
+	apiData, err := stageChanged(ctx, tx, client, s.Table, s.Endpoint, s.Columns, s.Row)
+	if errors.Is(err, ErrNotModified) {
+		slog.Info("Response unchanged since the last sync, skipping", "table", s.Table)
+		return SyncResult{}, nil
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	if apiData.rejected > 0 {
+		slog.Warn("Records were quarantined, keeping rows missing upstream",
+			"table", s.Table, "rejected", apiData.rejected)
+	}
+	result, err := upsertStaged(ctx, tx, s.Table, s.KeyColumn, s.Columns, apiData.table, apiData.rejected == 0)
+	if err != nil {
+		return result, err
+	}
+	result.Rejected = apiData.rejected
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("error committing %s sync: %w", s.Table, err)
+	}
+
+	slog.Info("Synced table", "table", s.Table,
+		"inserted", result.Inserted, "updated", result.Updated,
+		"unchanged", result.Unchanged, "deleted", result.Deleted, "rejected", result.Rejected)
+	apiData.commit()
+	return result, nil
+}
+
+// referenceSyncers are the reference datasets synced on a schedule.
+var referenceSyncers = []dataSyncer{
+	Syncer[structs.City]{
+		Name: "city", Endpoint: "cities", Table: "city", KeyColumn: "city_id",
+		Columns: cityColumns, Row: cityRow,
+	},
+	Syncer[structs.Country]{
+		Name: "country", Endpoint: "countries", Table: "country", KeyColumn: "country_iso_numeric",
+		Columns: countryColumns, Row: countryRow,
+	},
+	Syncer[structs.Airport]{
+		Name: "airport", Endpoint: "airports", Table: "airport", KeyColumn: "airport_id",
+		Columns: airportColumns, Row: airportRow,
+	},
+	Syncer[structs.Airplane]{
+		Name: "airplane", Endpoint: "airplanes", Table: "airplane", KeyColumn: "airplane_id",
+		Columns: airplaneColumns, Row: airplaneRow,
+	},
+	Syncer[structs.Tax]{
+		Name: "tax", Endpoint: "taxes", Table: "tax", KeyColumn: "tax_id",
+		Columns: taxColumns, Row: taxRow,
+	},
+	Syncer[structs.Airline]{
+		Name: "airline", Endpoint: "airlines", Table: "airline", KeyColumn: "airline_id",
+		Columns: airlineColumns, Row: airlineRow,
+	},
+	Syncer[structs.Aircraft]{
+		Name: "aircraft", Endpoint: "aircraft_types", Table: "aircraft", KeyColumn: "plane_type_id",
+		Columns: aircraftColumns, Row: aircraftRow,
+	},
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+)
+
+// SyncResult counts what a sync run did to its table.
+type SyncResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Deleted   int
+	// Rejected is the number of upstream records that were quarantined
+	Rejected int
+	// Events is the number of flight events recorded
+	Events int
+}
+
+// createStaging creates a temporary table shaped like table that is dropped
+// when tx ends, and returns its name. Records are copied into it page by
+// page; sync_row keeps their order so the last of a repeated key can win.
+func createStaging(ctx context.Context, tx pgx.Tx, table string) (string, error) {
+	staging := "sync_" + table
+	if _, err := tx.Exec(ctx, fmt.Sprintf(
+		`CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS, sync_row bigserial) ON COMMIT DROP`,
+		staging, table,
+	)); err != nil {
+		return "", fmt.Errorf("error creating %s staging table: %w", table, err)
+	}
+	return staging, nil
+}
+
+// stageSource copies the records of src into staging as they are decoded.
+func stageSource[T any](
+	ctx context.Context,
+	tx pgx.Tx,
+	staging string,
+	columns []string,
+	row func(T) []any,
+	src RecordSource[T],
+) error {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, &copySource[T]{src: src, row: row}); err != nil {
+		return fmt.Errorf("error copying data into %s: %w", staging, err)
+	}
+	return nil
+}
+
+// dedupeStaged drops every staged record but the last of each key and
+// returns how many are left.
+func dedupeStaged(ctx context.Context, tx pgx.Tx, staging string, key []string) (int, error) {
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %[1]s a USING %[1]s b
+		WHERE (a.%[2]s) = (b.%[3]s) AND a.sync_row < b.sync_row`,
+		staging, strings.Join(key, ", a."), strings.Join(key, ", b."),
+	)); err != nil {
+		return 0, fmt.Errorf("error removing repeated records from %s: %w", staging, err)
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s`, staging)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting records in %s: %w", staging, err)
+	}
+	return count, nil
+}
+
+// upsertStaged makes table match the records staged in staging, which must
+// have a distinct key each. New keys are inserted, rows whose columns differ
+// are updated and, when deleteMissing is set, rows whose key is no longer
+// upstream get deleted_at set. A soft-deleted row that reappears is
+// restored. created_at is only written on insert.
+//
+// An empty staging table deletes nothing, an empty response is more likely
+// an upstream problem than every row having gone. Callers clear
+// deleteMissing when the staged records are known to be incomplete, such as
+// when some upstream records were quarantined.
+func upsertStaged(
+	ctx context.Context,
+	tx pgx.Tx,
+	table, key string,
+	columns []string,
+	staging string,
+	deleteMissing bool,
+) (SyncResult, error) {
+	var result SyncResult
+
+	staged, err := dedupeStaged(ctx, tx, staging, []string{key})
+	if err != nil {
+		return result, err
+	}
+
+	if err := tx.QueryRow(ctx, upsertQuery(table, key, columns, staging)).Scan(&result.Inserted, &result.Updated); err != nil {
+		return result, fmt.Errorf("error upserting into %s table: %w", table, err)
+	}
+	result.Unchanged = staged - result.Inserted - result.Updated
+
+	if deleteMissing && staged > 0 {
+		tag, err := tx.Exec(ctx, deleteMissingQuery(table, key, staging))
+		if err != nil {
+			return result, fmt.Errorf("error deleting vanished %s rows: %w", table, err)
+		}
+		result.Deleted = int(tag.RowsAffected())
+	}
+	return result, nil
+}
+
+// upsertQuery builds the statement that copies staging into table and
+// returns how many rows it inserted and updated. A row is only updated when
+// one of its columns, other than the key and created_at, differs or it was
+// soft-deleted.
+func upsertQuery(table, key string, columns []string, staging string) string {
+	var set, current, incoming []string
+	for _, column := range columns {
+		if column == key || column == "created_at" {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = excluded.%s", column, column))
+		current = append(current, table+"."+column)
+		incoming = append(incoming, "excluded."+column)
+	}
+	list := strings.Join(columns, ", ")
+
+	// xmax is 0 for a row inserted by this statement
+	return fmt.Sprintf(`
+		WITH upserted AS (
+			INSERT INTO %[1]s (%[2]s)
+			SELECT %[2]s FROM %[4]s
+			ON CONFLICT (%[3]s) DO UPDATE
+			SET %[5]s, updated_at = now(), deleted_at = NULL
+			WHERE (%[6]s, %[1]s.deleted_at) IS DISTINCT FROM (%[7]s, NULL)
+			RETURNING xmax = 0 AS inserted
+		)
+		SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted`,
+		table, list, key, staging,
+		strings.Join(set, ", "), strings.Join(current, ", "), strings.Join(incoming, ", "),
+	)
+}
+
+// deleteMissingQuery builds the statement that soft-deletes the live rows of
+// table whose key is not in staging.
+func deleteMissingQuery(table, key, staging string) string {
+	return fmt.Sprintf(`
+		UPDATE %[1]s SET deleted_at = now()
+		WHERE deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM %[2]s WHERE %[2]s.%[3]s = %[1]s.%[3]s)`,
+		table, staging, key,
+	)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUpsertQuery(t *testing.T) {
+	query := upsertQuery("city", "city_id", []string{"city_id", "city_name", "timezone", "created_at"}, "sync_city")
+
+	for _, want := range []string{
+		"INSERT INTO city (city_id, city_name, timezone, created_at)",
+		"SELECT city_id, city_name, timezone, created_at FROM sync_city",
+		"ON CONFLICT (city_id) DO UPDATE",
+		"SET city_name = excluded.city_name, timezone = excluded.timezone, updated_at = now(), deleted_at = NULL",
+		"WHERE (city.city_name, city.timezone, city.deleted_at) IS DISTINCT FROM (excluded.city_name, excluded.timezone, NULL)",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("got query %s\nwant it to contain %q", query, want)
+		}
+	}
+	// the key and created_at are never overwritten
+	for _, unwanted := range []string{"city_id = excluded", "created_at = excluded"} {
+		if strings.Contains(query, unwanted) {
+			t.Errorf("got query %s\nwant no %q", query, unwanted)
+		}
+	}
+}
+
+func TestDeleteMissingQuery(t *testing.T) {
+	query := deleteMissingQuery("city", "city_id", "sync_city")
+	want := "NOT EXISTS (SELECT 1 FROM sync_city WHERE sync_city.city_id = city.city_id)"
+	if !strings.Contains(query, want) || !strings.Contains(query, "WHERE deleted_at IS NULL") {
+		t.Errorf("got query %s\nwant it to soft-delete live rows missing from sync_city", query)
+	}
+}
+
+func TestSyncerSync(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	const create = `CREATE TABLE upsert_test (
+		item_id int UNIQUE,
+		name text,
+		created_at timestamptz DEFAULT now(),
+		updated_at timestamptz,
+		deleted_at timestamptz
+	)`
+	if _, err := pool.Exec(ctx, `DROP TABLE IF EXISTS upsert_test`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, create); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Exec(ctx, `DROP TABLE IF EXISTS upsert_test`) })
+
+	var data atomic.Pointer[string]
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		records := *data.Load()
+		count := strings.Count(records, "{")
+		fmt.Fprintf(w, `{"pagination":{"offset":0,"limit":100,"count":%d,"total":%d},"data":[%s]}`, count, count, records)
+	}, "a")
+	client.quarantine = NewQuarantine(pool)
+
+	syncer := Syncer[streamRecord]{
+		Name: "upsert_test", Endpoint: "records", Table: "upsert_test", KeyColumn: "item_id",
+		Columns: []string{"item_id", "name"},
+		Row:     func(r streamRecord) []any { return []any{r.ID, r.Name} },
+	}
+
+	// the steps run in order against the same table
+	steps := []struct {
+		name string
+		data string
+		want SyncResult
+		live map[int]string
+	}{
+		{
+			name: "insert with a repeated id",
+			data: `{"id":1,"name":"a"},{"id":2,"name":"b"},{"id":2,"name":"b2"}`,
+			want: SyncResult{Inserted: 2},
+			live: map[int]string{1: "a", 2: "b2"},
+		},
+		{
+			name: "unchanged",
+			data: `{"id":1,"name":"a"},{"id":2,"name":"b2"}`,
+			want: SyncResult{Unchanged: 2},
+			live: map[int]string{1: "a", 2: "b2"},
+		},
+		{
+			name: "update",
+			data: `{"id":1,"name":"a"},{"id":2,"name":"c"}`,
+			want: SyncResult{Updated: 1, Unchanged: 1},
+			live: map[int]string{1: "a", 2: "c"},
+		},
+		{
+			name: "quarantined record keeps missing rows",
+			data: `{"id":1,"name":"a"},{"id":"x","name":"d"}`,
+			want: SyncResult{Unchanged: 1, Rejected: 1},
+			live: map[int]string{1: "a", 2: "c"},
+		},
+		{
+			name: "missing row soft-deleted",
+			data: `{"id":1,"name":"a"}`,
+			want: SyncResult{Unchanged: 1, Deleted: 1},
+			live: map[int]string{1: "a"},
+		},
+		{
+			name: "empty response deletes nothing",
+			data: ``,
+			want: SyncResult{},
+			live: map[int]string{1: "a"},
+		},
+		{
+			name: "deleted row restored",
+			data: `{"id":1,"name":"a"},{"id":2,"name":"c"}`,
+			want: SyncResult{Updated: 1, Unchanged: 1},
+			live: map[int]string{1: "a", 2: "c"},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			data.Store(&step.data)
+			result, err := syncer.sync(ctx, pool, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != step.want {
+				t.Errorf("got result %+v, want %+v", result, step.want)
+			}
+
+			rows, err := pool.Query(ctx, `SELECT item_id, name FROM upsert_test WHERE deleted_at IS NULL`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			live := make(map[int]string)
+			for rows.Next() {
+				var id int
+				var name string
+				if err := rows.Scan(&id, &name); err != nil {
+					t.Fatal(err)
+				}
+				live[id] = name
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(live, step.live) {
+				t.Errorf("got live rows %v, want %v", live, step.live)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/api"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage:
+  %[1]s                                    run the server
+  %[1]s sync <dataset>                     sync one dataset now
+  %[1]s retries [list] [pending|dead]      list failed jobs waiting for a retry
+  %[1]s retries retry <id>                 retry a queued job now
+  %[1]s retries discard <id>               remove a job from the retry queue
+`
+
+var errUsage = errors.New("invalid command")
+
+// runCommand runs the command in args, the arguments after the program name.
+func runCommand(ctx context.Context, jobs *api.ServiceJob, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "sync":
+		return jobs.Run(ctx, args[1], api.TriggerManual)
+	case len(args) >= 1 && args[0] == "retries":
+		return retriesCommand(ctx, jobs, args[1:])
+	default:
+		return errUsage
+	}
+}
+
+func retriesCommand(ctx context.Context, jobs *api.ServiceJob, args []string) error {
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+
+	switch {
+	case len(args) == 0:
+		return listRetries(ctx, jobs, "")
+	case len(args) == 1 && (args[0] == api.RetryPending || args[0] == api.RetryDead):
+		return listRetries(ctx, jobs, args[0])
+	case len(args) == 2 && args[0] == "retry":
+		return jobs.RetryNow(ctx, args[1])
+	case len(args) == 2 && args[0] == "discard":
+		return jobs.DiscardRetry(ctx, args[1])
+	default:
+		return errUsage
+	}
+}
+
+func listRetries(ctx context.Context, jobs *api.ServiceJob, status string) error {
+	retries, err := jobs.Retries(ctx, status)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tJOB\tSTATUS\tATTEMPTS\tNEXT ATTEMPT\tLAST ERROR")
+	for _, retry := range retries {
+		next := retry.NextAttemptAt.Local().Format(time.DateTime)
+		if retry.Status == api.RetryDead {
+			next = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			retry.ID, retry.Job, retry.Status, retry.Attempts, next, retry.LastError)
+	}
+	return w.Flush()
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/joho/godotenv"
 	"log"
@@ -13,10 +14,14 @@ import (
 )
 
 type Config struct {
-	Log      *LogConfig
-	Database *DatabaseConfig
-	Redis    *RedisConfig
-	Server   *ServerConfig
+	Log           *LogConfig
+	Database      *DatabaseConfig
+	Redis         *RedisConfig
+	Server        *ServerConfig
+	AviationStack *AviationStackConfig
+	ReferenceData *ReferenceDataConfig
+	Ingest        *IngestConfig
+	Jobs          *JobsConfig
 }
 
 type LogConfig struct {
@@ -41,6 +46,78 @@ type ServerConfig struct {
 	IdleTimeout     time.Duration
 	GracefulTimeout time.Duration
 	SessionKey      string
+	// AdminEmails are the users allowed on the /admin pages
+	AdminEmails []string
+}
+
+type ReferenceDataConfig struct {
+	Provider       string
+	OpenFlightsDir string
+	OurAirportsDir string
+}
+
+type JobsConfig struct {
+	SeedTimeout time.Duration
+	// SeedConcurrency caps how many datasets are seeded at once
+	SeedConcurrency int
+	SyncTimeout     time.Duration
+	LockTTL         time.Duration
+	Datasets        map[string]DatasetJobConfig
+	// A failed sync is retried after RetryBaseDelay, doubling up to
+	// RetryMaxDelay, until it failed RetryMaxAttempts times
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryInterval    time.Duration
+	// FlightsQuery holds "key=value" AviationStack parameters narrowing the
+	// flights poll, e.g. "dep_iata=LIS"
+	FlightsQuery []string
+}
+
+// DatasetJobConfig schedules the sync of one dataset. A disabled dataset
+// can still be synced by hand.
+type DatasetJobConfig struct {
+	Enabled  bool
+	Schedule string
+	Timeout  time.Duration
+}
+
+type IngestConfig struct {
+	SBSAddr           string
+	SBSFile           string
+	ModeSAddr         string
+	ModeSFile         string
+	ModeSFormat       string
+	ReceiverLatitude  *float64
+	ReceiverLongitude *float64
+	OpenSkyURL        string
+	OpenSkyFile       string
+	OpenSkyUsername   string
+	OpenSkyPassword   string
+	OpenSkyInterval   time.Duration
+	FlushInterval     time.Duration
+	ReconnectDelay    time.Duration
+}
+
+type AviationStackConfig struct {
+	BaseURL               string
+	AccessKeys            []string
+	UserAgent             string
+	Timeout               time.Duration
+	ResponseHeaderTimeout time.Duration
+	PageSize              int
+	Concurrency           int
+	MaxRetries            int
+	RetryBaseDelay        time.Duration
+	RetryMaxDelay         time.Duration
+	Mode                  string
+	RecordingsDir         string
+	CacheEnabled          bool
+	CacheTTLs             map[string]time.Duration
+	CacheDefaultTTL       time.Duration
+	MonthlyQuota          int
+	QuotaReservePercent   int
+	BillingDay            int
 }
 
 func NewConfig() (*Config, error) {
@@ -59,11 +136,30 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
+	aviationStack, err := NewAviationStackConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ingest, err := NewIngestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := NewJobsConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Log:      NewLogConfig(),
-		Database: database,
-		Server:   server,
-		Redis:    redisClient,
+		Log:           NewLogConfig(),
+		Database:      database,
+		Server:        server,
+		Redis:         redisClient,
+		AviationStack: aviationStack,
+		ReferenceData: NewReferenceDataConfig(),
+		Ingest:        ingest,
+		Jobs:          jobs,
 	}, nil
 }
 
@@ -182,6 +278,13 @@ func NewServerConfig() (*ServerConfig, error) {
 	}
 	sessionKey := GetEnv("session_key", "super-secret")
 
+	var adminEmails []string
+	for _, email := range strings.Split(GetEnv("admin_emails", ""), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			adminEmails = append(adminEmails, strings.ToLower(email))
+		}
+	}
+
 	return &ServerConfig{
 		Addr:            addr,
 		GracefulTimeout: gracefulTimeout,
@@ -189,5 +292,358 @@ func NewServerConfig() (*ServerConfig, error) {
 		ReadTimeout:     readTimeout,
 		IdleTimeout:     idleTimeout,
 		SessionKey:      sessionKey,
+		AdminEmails:     adminEmails,
+	}, nil
+}
+
+// NewReferenceDataConfig selects where airports, airlines, aircraft etc.
+// are seeded from: "aviationstack", "openflights" or "ourairports".
+// Runways, frequencies and navaids are only imported when OURAIRPORTS_DIR
+// is set.
+func NewReferenceDataConfig() *ReferenceDataConfig {
+	return &ReferenceDataConfig{
+		Provider:       strings.ToLower(GetEnv("reference_data_provider", "aviationstack")),
+		OpenFlightsDir: GetEnv("openflights_dir", "./api/data/openflights"),
+		OurAirportsDir: GetEnv("ourairports_dir", ""),
+	}
+}
+
+func NewAviationStackConfig() (*AviationStackConfig, error) {
+	baseURL := GetEnv("aviation_stack_base_url", "http://api.aviationstack.com/v1/")
+	// several keys, comma separated, are rotated as each one's quota runs out
+	var accessKeys []string
+	for _, key := range strings.Split(GetEnv("aviation_stack_api_key", ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			accessKeys = append(accessKeys, key)
+		}
+	}
+	userAgent := GetEnv("aviation_stack_user_agent", "aviation-tracker")
+	timeout, err := time.ParseDuration(GetEnv("aviation_stack_timeout", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_TIMEOUT: %w", err)
+	}
+	responseHeaderTimeout, err := time.ParseDuration(GetEnv("aviation_stack_response_header_timeout", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_RESPONSE_HEADER_TIMEOUT: %w", err)
+	}
+	pageSize, err := strconv.Atoi(GetEnv("aviation_stack_page_size", "100"))
+	if err != nil || pageSize <= 0 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_PAGE_SIZE: %q", GetEnv("aviation_stack_page_size", "100"))
+	}
+	concurrency, err := strconv.Atoi(GetEnv("aviation_stack_concurrency", "4"))
+	if err != nil || concurrency <= 0 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_CONCURRENCY: %q", GetEnv("aviation_stack_concurrency", "4"))
+	}
+	maxRetries, err := strconv.Atoi(GetEnv("aviation_stack_max_retries", "4"))
+	if err != nil || maxRetries < 0 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_MAX_RETRIES: %q", GetEnv("aviation_stack_max_retries", "4"))
+	}
+	retryBaseDelay, err := time.ParseDuration(GetEnv("aviation_stack_retry_base_delay", "500ms"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_RETRY_BASE_DELAY: %w", err)
+	}
+	retryMaxDelay, err := time.ParseDuration(GetEnv("aviation_stack_retry_max_delay", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_RETRY_MAX_DELAY: %w", err)
+	}
+	// live, record (save every response to RecordingsDir) or replay (serve them back offline)
+	mode := strings.ToLower(GetEnv("aviation_stack_mode", "live"))
+	recordingsDir := GetEnv("aviation_stack_recordings_dir", "./api/data/recordings")
+	cacheEnabled, err := strconv.ParseBool(GetEnv("aviation_stack_cache", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_CACHE: %w", err)
+	}
+	// endpoint=duration pairs, endpoints not listed use the default TTL
+	cacheTTLs, err := parseDurations(GetEnv("aviation_stack_cache_ttls",
+		"cities=168h,countries=168h,taxes=168h,aircraft_types=168h,flights=1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_CACHE_TTLS: %w", err)
+	}
+	cacheDefaultTTL, err := time.ParseDuration(GetEnv("aviation_stack_cache_default_ttl", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_CACHE_DEFAULT_TTL: %w", err)
+	}
+
+	// requests per key and billing month, 0 leaves them uncounted and unlimited
+	monthlyQuota, err := strconv.Atoi(GetEnv("aviation_stack_monthly_quota", "0"))
+	if err != nil || monthlyQuota < 0 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_MONTHLY_QUOTA: %q", GetEnv("aviation_stack_monthly_quota", "0"))
+	}
+	// share of the quota kept for critical work such as seeding and flights
+	quotaReservePercent, err := strconv.Atoi(GetEnv("aviation_stack_quota_reserve_percent", "10"))
+	if err != nil || quotaReservePercent < 0 || quotaReservePercent > 100 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_QUOTA_RESERVE_PERCENT: %q",
+			GetEnv("aviation_stack_quota_reserve_percent", "10"))
+	}
+	billingDay, err := strconv.Atoi(GetEnv("aviation_stack_billing_day", "1"))
+	if err != nil || billingDay < 1 || billingDay > 28 {
+		return nil, fmt.Errorf("invalid AVIATION_STACK_BILLING_DAY: %q", GetEnv("aviation_stack_billing_day", "1"))
+	}
+
+	return &AviationStackConfig{
+		BaseURL:               baseURL,
+		AccessKeys:            accessKeys,
+		UserAgent:             userAgent,
+		Timeout:               timeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		PageSize:              pageSize,
+		Concurrency:           concurrency,
+		MaxRetries:            maxRetries,
+		RetryBaseDelay:        retryBaseDelay,
+		RetryMaxDelay:         retryMaxDelay,
+		Mode:                  mode,
+		RecordingsDir:         recordingsDir,
+		CacheEnabled:          cacheEnabled,
+		CacheTTLs:             cacheTTLs,
+		CacheDefaultTTL:       cacheDefaultTTL,
+		MonthlyQuota:          monthlyQuota,
+		QuotaReservePercent:   quotaReservePercent,
+		BillingDay:            billingDay,
+	}, nil
+}
+
+// parseDurations parses a comma separated list of name=duration pairs.
+func parseDurations(s string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=duration, got %q", pair)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations, nil
+}
+
+// NewIngestConfig configures the live position feeds. SBS_ADDR is a
+// BaseStation (port 30003) host:port, SBS_FILE a recorded SBS log; MODES_ADDR
+// and MODES_FILE the same for raw frames in MODES_FORMAT ("beast" or "avr").
+// OPENSKY_URL is polled for OpenSky /states/all vectors, OPENSKY_FILE a saved
+// response. Feeds are disabled when neither address nor file is set.
+func NewIngestConfig() (*IngestConfig, error) {
+	flushInterval, err := time.ParseDuration(GetEnv("ingest_flush_interval", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INGEST_FLUSH_INTERVAL: %w", err)
+	}
+	reconnectDelay, err := time.ParseDuration(GetEnv("ingest_reconnect_delay", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INGEST_RECONNECT_DELAY: %w", err)
+	}
+
+	openSkyInterval, err := time.ParseDuration(GetEnv("opensky_interval", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPENSKY_INTERVAL: %w", err)
+	}
+	receiverLatitude, err := optionalFloat("receiver_latitude")
+	if err != nil {
+		return nil, err
+	}
+	receiverLongitude, err := optionalFloat("receiver_longitude")
+	if err != nil {
+		return nil, err
+	}
+
+	return &IngestConfig{
+		SBSAddr:           GetEnv("sbs_addr", ""),
+		SBSFile:           GetEnv("sbs_file", ""),
+		ModeSAddr:         GetEnv("modes_addr", ""),
+		ModeSFile:         GetEnv("modes_file", ""),
+		ModeSFormat:       strings.ToLower(GetEnv("modes_format", "beast")),
+		ReceiverLatitude:  receiverLatitude,
+		ReceiverLongitude: receiverLongitude,
+		OpenSkyURL:        GetEnv("opensky_url", ""),
+		OpenSkyFile:       GetEnv("opensky_file", ""),
+		OpenSkyUsername:   GetEnv("opensky_username", ""),
+		OpenSkyPassword:   GetEnv("opensky_password", ""),
+		OpenSkyInterval:   openSkyInterval,
+		FlushInterval:     flushInterval,
+		ReconnectDelay:    reconnectDelay,
+	}, nil
+}
+
+func optionalFloat(key string) (*float64, error) {
+	val := GetEnv(key, "")
+	if val == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", strings.ToUpper(key), err)
+	}
+	return &f, nil
+}
+
+// defaultJobSchedules are the synced datasets with their default cron spec.
+var defaultJobSchedules = map[string]string{
+	"city":     "@weekly",
+	"country":  "@weekly",
+	"airport":  "@weekly",
+	"airplane": "@weekly",
+	"tax":      "@weekly",
+	"airline":  "@weekly",
+	"aircraft": "@daily",
+	"flights":  "@every 10m",
+}
+
+// jobsFile is the JOBS_CONFIG file. A field left out keeps its default.
+type jobsFile struct {
+	Datasets map[string]struct {
+		Enabled  *bool   `json:"enabled"`
+		Schedule *string `json:"schedule"`
+		Timeout  *string `json:"timeout"`
+	} `json:"datasets"`
+}
+
+// readJobsFile reads the JSON file at path, which configures the datasets'
+// sync jobs like the SYNC_<DATASET>_* variables:
+//
+//	{"datasets": {"flights": {"schedule": "@every 5m", "timeout": "2m"}, "tax": {"enabled": false}}}
+//
+// An empty path is an empty file.
+func readJobsFile(path string) (jobsFile, error) {
+	var file jobsFile
+	if path == "" {
+		return file, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return file, fmt.Errorf("invalid JOBS_CONFIG: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return file, fmt.Errorf("invalid JOBS_CONFIG %s: %w", path, err)
+	}
+	for name := range file.Datasets {
+		if _, ok := defaultJobSchedules[name]; !ok {
+			return file, fmt.Errorf("invalid JOBS_CONFIG %s: unknown dataset %q", path, name)
+		}
+	}
+	return file, nil
+}
+
+// NewJobsConfig bounds how long seeding one dataset at startup and one
+// scheduled sync run may take, and how many datasets SEED_CONCURRENCY seeds
+// at once. Each dataset's sync is configured with
+// SYNC_<DATASET>_ENABLED, SYNC_<DATASET>_SCHEDULE (a cron spec) and
+// SYNC_<DATASET>_TIMEOUT, which defaults to SYNC_TIMEOUT; these override
+// the JOBS_CONFIG file, see readJobsFile, which overrides the defaults.
+// JOB_LOCK_TTL is the lease of the lock a running job holds, and how long a
+// job whose instance died stays locked. FLIGHTS_QUERY is a URL query string
+// narrowing the flights poll, which is disabled by default without it.
+// Failed syncs are retried every RETRY_INTERVAL, after RETRY_BASE_DELAY
+// doubling up to RETRY_MAX_DELAY, RETRY_MAX_ATTEMPTS times.
+func NewJobsConfig() (*JobsConfig, error) {
+	seedTimeout, err := time.ParseDuration(GetEnv("seed_timeout", "30m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SEED_TIMEOUT: %w", err)
+	}
+	seedConcurrency, err := strconv.Atoi(GetEnv("seed_concurrency", "3"))
+	if err != nil || seedConcurrency < 1 {
+		return nil, fmt.Errorf("invalid SEED_CONCURRENCY: must be a positive number")
+	}
+	syncTimeout, err := time.ParseDuration(GetEnv("sync_timeout", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SYNC_TIMEOUT: %w", err)
+	}
+	lockTTL, err := time.ParseDuration(GetEnv("job_lock_ttl", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_LOCK_TTL: %w", err)
+	}
+	if lockTTL < time.Second {
+		return nil, fmt.Errorf("invalid JOB_LOCK_TTL: %s is shorter than a second", lockTTL)
+	}
+
+	flightsQuery, err := url.ParseQuery(GetEnv("flights_query", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FLIGHTS_QUERY: %w", err)
+	}
+	var flightsParams []string
+	for key, values := range flightsQuery {
+		for _, value := range values {
+			flightsParams = append(flightsParams, key+"="+value)
+		}
+	}
+
+	jobsFile, err := readJobsFile(GetEnv("jobs_config", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	datasets := make(map[string]DatasetJobConfig, len(defaultJobSchedules))
+	for name, schedule := range defaultJobSchedules {
+		file := jobsFile.Datasets[name]
+		enabledDefault, timeoutDefault := "true", ""
+		if name == "flights" && len(flightsParams) == 0 {
+			// an unfiltered poll walks every page of /flights
+			enabledDefault = "false"
+		}
+		if file.Enabled != nil {
+			enabledDefault = strconv.FormatBool(*file.Enabled)
+		}
+		if file.Schedule != nil {
+			schedule = *file.Schedule
+		}
+		if file.Timeout != nil {
+			timeoutDefault = *file.Timeout
+		}
+
+		prefix := "sync_" + name + "_"
+		enabled, err := strconv.ParseBool(GetEnv(prefix+"enabled", enabledDefault))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sENABLED: %w", strings.ToUpper(prefix), err)
+		}
+		timeout := syncTimeout
+		if val := GetEnv(prefix+"timeout", timeoutDefault); val != "" {
+			if timeout, err = time.ParseDuration(val); err != nil {
+				return nil, fmt.Errorf("invalid %sTIMEOUT: %w", strings.ToUpper(prefix), err)
+			}
+		}
+		datasets[name] = DatasetJobConfig{
+			Enabled:  enabled,
+			Schedule: GetEnv(prefix+"schedule", schedule),
+			Timeout:  timeout,
+		}
+	}
+
+	retryMaxAttempts, err := strconv.Atoi(GetEnv("retry_max_attempts", "5"))
+	if err != nil || retryMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid RETRY_MAX_ATTEMPTS: must be a positive number")
+	}
+	retryBaseDelay, err := time.ParseDuration(GetEnv("retry_base_delay", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETRY_BASE_DELAY: %w", err)
+	}
+	retryMaxDelay, err := time.ParseDuration(GetEnv("retry_max_delay", "6h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETRY_MAX_DELAY: %w", err)
+	}
+	retryInterval, err := time.ParseDuration(GetEnv("retry_interval", "1m"))
+	if err != nil || retryInterval <= 0 {
+		return nil, fmt.Errorf("invalid RETRY_INTERVAL: must be a positive duration")
+	}
+
+	return &JobsConfig{
+		SeedTimeout:     seedTimeout,
+		SeedConcurrency: seedConcurrency,
+		SyncTimeout:     syncTimeout,
+		LockTTL:         lockTTL,
+		Datasets:        datasets,
+		FlightsQuery:    flightsParams,
+
+		RetryMaxAttempts: retryMaxAttempts,
+		RetryBaseDelay:   retryBaseDelay,
+		RetryMaxDelay:    retryMaxDelay,
+		RetryInterval:    retryInterval,
 	}, nil
 }
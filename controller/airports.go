@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"strings"
+)
+
+// airportJSON returns an airport with its runways, frequencies and navaids.
+func (h *Handlers) airportJSON(w http.ResponseWriter, r *http.Request) error {
+	icao := strings.ToUpper(mux.Vars(r)["icao"])
+	airport, err := h.core.airports.AirportByICAO(r.Context(), icao)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "airport not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(airport)
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"github.com/FACorreiaa/go-ollama/core/account"
 	"net/http"
+	"strings"
 )
 
 type ctxKey int
 
 const (
 	ctxKeyAuthUser ctxKey = iota
+	ctxKeyAdmin
 )
 
 // Middleware to set the current logged in user in the context.
@@ -25,6 +27,7 @@ func (h *Handlers) authMiddleware(next http.Handler) http.Handler {
 
 			if err == nil {
 				ctx := context.WithValue(r.Context(), ctxKeyAuthUser, user)
+				ctx = context.WithValue(ctx, ctxKeyAdmin, h.admins[strings.ToLower(user.Email)])
 				r = r.WithContext(ctx)
 			}
 		}
@@ -45,6 +48,24 @@ func (h *Handlers) requireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin answers 403 to users not in ADMIN_EMAILS. It goes after
+// requireAuth.
+func (h *Handlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAdmin(r *http.Request) bool {
+	admin, _ := r.Context().Value(ctxKeyAdmin).(bool)
+	return admin
+}
+
 func (h *Handlers) redirectIfAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(ctxKeyAuthUser)
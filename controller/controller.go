@@ -3,6 +3,7 @@ package controller
 import (
 	"embed"
 	"errors"
+	"github.com/FACorreiaa/go-ollama/api"
 	"github.com/FACorreiaa/go-ollama/core/account"
 	"github.com/go-playground/form/v4"
 	"github.com/go-playground/locales/en"
@@ -15,6 +16,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"net/http"
+	"strings"
 )
 
 //go:embed static
@@ -25,6 +27,10 @@ var htmlFS embed.FS
 
 type core struct {
 	accounts *account.Accounts
+	airports *api.AirportRepository
+	flights  *api.FlightRepository
+	quota    *api.QuotaTracker
+	jobs     *api.ServiceJob
 }
 
 type Handlers struct {
@@ -33,11 +39,20 @@ type Handlers struct {
 	validator   *validator.Validate
 	translator  ut.Translator
 	sessions    *sessions.CookieStore
+	// admins holds the lower-cased emails of the users allowed on /admin
+	admins      map[string]bool
 	core        *core
 	redisClient *redis.Client
 }
 
-func Router(pool *pgxpool.Pool, sessionSecret []byte, redisClient *redis.Client) http.Handler {
+func Router(
+	pool *pgxpool.Pool,
+	sessionSecret []byte,
+	adminEmails []string,
+	redisClient *redis.Client,
+	quota *api.QuotaTracker,
+	jobs *api.ServiceJob,
+) http.Handler {
 	validate := validator.New()
 	translator, _ := ut.New(en.New(), en.New()).GetTranslator("en")
 	if err := en_translations.RegisterDefaultTranslations(validate, translator); err != nil {
@@ -46,6 +61,11 @@ func Router(pool *pgxpool.Pool, sessionSecret []byte, redisClient *redis.Client)
 
 	formDecoder := form.NewDecoder()
 
+	admins := make(map[string]bool, len(adminEmails))
+	for _, email := range adminEmails {
+		admins[strings.ToLower(email)] = true
+	}
+
 	r := mux.NewRouter()
 	h := Handlers{
 		pgpool:      pool,
@@ -53,9 +73,14 @@ func Router(pool *pgxpool.Pool, sessionSecret []byte, redisClient *redis.Client)
 		validator:   validate,
 		translator:  translator,
 		sessions:    sessions.NewCookieStore(sessionSecret),
+		admins:      admins,
 		redisClient: redisClient,
 		core: &core{
 			accounts: account.NewAccounts(pool, redisClient, validate),
+			airports: api.NewAirportRepository(pool),
+			flights:  api.NewFlightRepository(pool),
+			quota:    quota,
+			jobs:     jobs,
 		},
 	}
 
@@ -71,6 +96,11 @@ func Router(pool *pgxpool.Pool, sessionSecret []byte, redisClient *redis.Client)
 	optAuth := r.NewRoute().Subrouter()
 	optAuth.Use(h.authMiddleware)
 	optAuth.HandleFunc("/", handler(h.homePage)).Methods(http.MethodGet)
+	optAuth.HandleFunc("/airports/{icao}", handler(h.airportJSON)).Methods(http.MethodGet)
+	optAuth.HandleFunc(
+		"/flights/{flight_iata}/{flight_date}/{departure_iata}/events",
+		handler(h.flightEventsJSON),
+	).Methods(http.MethodGet)
 
 	// Routes that shouldn't be available to authenticated users
 	noAuth := r.NewRoute().Subrouter()
@@ -90,6 +120,20 @@ func Router(pool *pgxpool.Pool, sessionSecret []byte, redisClient *redis.Client)
 	auth.HandleFunc("/logout", handler(h.logout)).Methods(http.MethodPost)
 	auth.HandleFunc("/settings", handler(h.settingsPage)).Methods(http.MethodGet)
 
+	// Admin routes, only for the users in ADMIN_EMAILS
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(h.authMiddleware)
+	admin.Use(h.requireAuth)
+	admin.Use(h.requireAdmin)
+	admin.Use(h.requireCSRF)
+
+	admin.HandleFunc("/quota", handler(h.quotaPage)).Methods(http.MethodGet)
+	admin.HandleFunc("/jobs", handler(h.jobsPage)).Methods(http.MethodGet)
+	admin.HandleFunc("/jobs.json", handler(h.jobsJSON)).Methods(http.MethodGet)
+	admin.HandleFunc("/jobs/{job}/run", handler(h.runJob)).Methods(http.MethodPost)
+	admin.HandleFunc("/retries/{id}/retry", handler(h.retryJob)).Methods(http.MethodPost)
+	admin.HandleFunc("/retries/{id}/discard", handler(h.discardRetry)).Methods(http.MethodPost)
+
 	return r
 }
 
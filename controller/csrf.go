@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// csrfField is the form field carrying the CSRF token.
+const csrfField = "csrf_token"
+
+// csrfToken returns the CSRF token of the session, creating it on first use.
+// Forms posting to routes behind requireCSRF send it in csrfField.
+func (h *Handlers) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	session, _ := h.sessions.Get(r, "auth")
+	if token, ok := session.Values["csrf"].(string); ok && token != "" {
+		return token, nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	session.Values["csrf"] = token
+	if err := session.Save(r, w); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// requireCSRF answers 403 to POST requests whose csrfField does not match
+// the session's CSRF token.
+func (h *Handlers) requireCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, _ := h.sessions.Get(r, "auth")
+		token, _ := session.Values["csrf"].(string)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.PostFormValue(csrfField))) != 1 {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
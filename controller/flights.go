@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"strings"
+)
+
+// flightEventsJSON returns the event timeline of the flight identified by
+// its IATA flight number, date and departure airport.
+func (h *Handlers) flightEventsJSON(w http.ResponseWriter, r *http.Request) error {
+	vars := mux.Vars(r)
+	timeline, err := h.core.flights.FlightTimeline(
+		r.Context(),
+		strings.ToUpper(vars["flight_iata"]),
+		vars["flight_date"],
+		strings.ToUpper(vars["departure_iata"]),
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "flight not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(timeline)
+}
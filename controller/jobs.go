@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/FACorreiaa/go-ollama/api"
+	"github.com/gorilla/mux"
+	"html/template"
+	"net/http"
+)
+
+var jobsPageTmpl = template.Must(template.ParseFS(
+	htmlFS,
+	"html/layout.html",
+	"html/jobs.html",
+))
+
+// jobRunsLimit is how many recent runs the jobs page and JSON list.
+const jobRunsLimit = 50
+
+type JobsPage struct {
+	// CSRFToken is sent with the run, retry and discard forms
+	CSRFToken string             `json:"-"`
+	Status    string             `json:"status,omitempty"`
+	Schedule  []api.ScheduledJob `json:"schedule"`
+	Retries   []api.JobRetry     `json:"retries"`
+	Runs      []api.JobRun       `json:"runs"`
+}
+
+// jobsData lists the scheduled jobs and the recent runs, only the runs with
+// the status given in the query when there is one.
+func (h *Handlers) jobsData(r *http.Request) (JobsPage, error) {
+	status := r.URL.Query().Get("status")
+	runs, err := h.core.jobs.Runs(r.Context(), status, jobRunsLimit)
+	if err != nil {
+		return JobsPage{}, err
+	}
+	retries, err := h.core.jobs.Retries(r.Context(), "")
+	if err != nil {
+		return JobsPage{}, err
+	}
+
+	return JobsPage{Status: status, Schedule: h.core.jobs.Schedule(), Retries: retries, Runs: runs}, nil
+}
+
+func (h *Handlers) jobsPage(w http.ResponseWriter, r *http.Request) error {
+	page, err := h.jobsData(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	if page.CSRFToken, err = h.csrfToken(w, r); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	return jobsPageTmpl.Execute(w, CreateLayout[JobsPage](r, "Jobs", page))
+}
+
+func (h *Handlers) jobsJSON(w http.ResponseWriter, r *http.Request) error {
+	page, err := h.jobsData(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(page)
+}
+
+// runJob starts a manual sync of the job in the path and goes back to the
+// jobs page, where the run shows up.
+func (h *Handlers) runJob(w http.ResponseWriter, r *http.Request) error {
+	err := h.core.jobs.Trigger(mux.Vars(r)["job"])
+	if errors.Is(err, api.ErrUnknownJob) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	http.Redirect(w, r, "/admin/jobs", http.StatusSeeOther)
+	return nil
+}
+
+// retryJob makes the queued retry in the path due now.
+func (h *Handlers) retryJob(w http.ResponseWriter, r *http.Request) error {
+	return h.updateRetry(w, r, h.core.jobs.RetryNow)
+}
+
+// discardRetry removes the queued retry in the path.
+func (h *Handlers) discardRetry(w http.ResponseWriter, r *http.Request) error {
+	return h.updateRetry(w, r, h.core.jobs.DiscardRetry)
+}
+
+func (h *Handlers) updateRetry(
+	w http.ResponseWriter,
+	r *http.Request,
+	update func(ctx context.Context, id string) error,
+) error {
+	err := update(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, api.ErrRetryNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	http.Redirect(w, r, "/admin/jobs", http.StatusSeeOther)
+	return nil
+}
@@ -40,6 +40,12 @@ func CreateLayout[T any](r *http.Request, title string, data T) Layout[T] {
 			{Path: "/editor", Label: "New Article", Icon: "ion-compose"},
 			{Path: "/settings", Label: "Settings", Icon: "ion-gear-a"},
 		}
+		if isAdmin(r) {
+			nav = append(nav,
+				NavItem{Path: "/admin/quota", Label: "Quota", Icon: "ion-stats-bars"},
+				NavItem{Path: "/admin/jobs", Label: "Jobs", Icon: "ion-clock"},
+			)
+		}
 	}
 
 	return Layout[T]{
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"github.com/FACorreiaa/go-ollama/api"
+	"html/template"
+	"net/http"
+)
+
+var quotaPageTmpl = template.Must(template.ParseFS(
+	htmlFS,
+	"html/layout.html",
+	"html/quota.html",
+))
+
+type QuotaPage struct {
+	Enabled bool
+	Usage   api.QuotaUsage
+}
+
+func (h *Handlers) quotaPage(w http.ResponseWriter, r *http.Request) error {
+	page := QuotaPage{Enabled: h.core.quota != nil}
+	if page.Enabled {
+		usage, err := h.core.quota.Usage(r.Context())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return err
+		}
+		page.Usage = usage
+	}
+
+	return quotaPageTmpl.Execute(w, CreateLayout[QuotaPage](r, "Quota", page))
+}
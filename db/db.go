@@ -1,12 +1,18 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"crypto/md5"
 	"embed"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"io/fs"
 	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -48,6 +54,10 @@ func Migrate(conn *pgxpool.Pool) error {
 	if err != nil {
 		return err
 	}
+	// ReadDir sorts by name, which would run 10_x before 2_x
+	slices.SortStableFunc(files, func(a, b fs.DirEntry) int {
+		return cmp.Compare(migrationNumber(a.Name()), migrationNumber(b.Name()))
+	})
 
 	slog.Info("Creating migrations table")
 	_, err = conn.Exec(ctx, `
@@ -109,6 +119,16 @@ func Migrate(conn *pgxpool.Pool) error {
 	return nil
 }
 
+// migrationNumber is the numeric prefix of a migration file name.
+func migrationNumber(name string) int {
+	prefix, _, _ := strings.Cut(name, "_")
+	n, err := strconv.Atoi(prefix)
+	if err != nil {
+		return math.MaxInt
+	}
+	return n
+}
+
 // WaitForDB Small hack to wait for database to start inside docker
 func WaitForDB(pgpool *pgxpool.Pool) {
 	ctx := context.Background()
@@ -0,0 +1,8 @@
+CREATE TABLE api_quota (
+                         key_id varchar(16) NOT NULL,
+                         period date NOT NULL,
+                         requests INT NOT NULL DEFAULT 0,
+                         exhausted bool NOT NULL DEFAULT false,
+                         updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW (),
+                         PRIMARY KEY (key_id, period)
+);
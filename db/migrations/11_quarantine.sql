@@ -0,0 +1,9 @@
+CREATE TABLE quarantine (
+                          id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                          dataset varchar(255) NOT NULL,
+                          raw jsonb NOT NULL,
+                          error text NOT NULL,
+                          created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE INDEX quarantine_dataset_idx ON quarantine (dataset, created_at);
@@ -0,0 +1,30 @@
+-- Reference tables are synced by upserting on their upstream id, so each id
+-- has to be unique. Rows removed upstream are kept with deleted_at set.
+
+ALTER TABLE city ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM city a USING city b WHERE a.city_id = b.city_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX city_city_id_key ON city (city_id);
+
+ALTER TABLE country ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM country a USING country b WHERE a.country_iso_numeric = b.country_iso_numeric AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX country_country_iso_numeric_key ON country (country_iso_numeric);
+
+ALTER TABLE airport ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM airport a USING airport b WHERE a.airport_id = b.airport_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX airport_airport_id_key ON airport (airport_id);
+
+ALTER TABLE airplane ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM airplane a USING airplane b WHERE a.airplane_id = b.airplane_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX airplane_airplane_id_key ON airplane (airplane_id);
+
+ALTER TABLE tax ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM tax a USING tax b WHERE a.tax_id = b.tax_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX tax_tax_id_key ON tax (tax_id);
+
+ALTER TABLE airline ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM airline a USING airline b WHERE a.airline_id = b.airline_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX airline_airline_id_key ON airline (airline_id);
+
+ALTER TABLE aircraft ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE, ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+DELETE FROM aircraft a USING aircraft b WHERE a.plane_type_id = b.plane_type_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX aircraft_plane_type_id_key ON aircraft (plane_type_id);
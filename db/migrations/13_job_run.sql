@@ -0,0 +1,17 @@
+CREATE TABLE job_run (
+                       id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                       job varchar(255) NOT NULL,
+                       trigger varchar(20) NOT NULL,
+                       status varchar(20) NOT NULL DEFAULT 'running',
+                       started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW (),
+                       finished_at TIMESTAMP WITH TIME ZONE,
+                       inserted INT NOT NULL DEFAULT 0,
+                       updated INT NOT NULL DEFAULT 0,
+                       unchanged INT NOT NULL DEFAULT 0,
+                       deleted INT NOT NULL DEFAULT 0,
+                       upstream_calls INT NOT NULL DEFAULT 0,
+                       error text
+);
+
+CREATE INDEX job_run_started_at_idx ON job_run (started_at DESC);
+CREATE INDEX job_run_job_idx ON job_run (job, started_at DESC);
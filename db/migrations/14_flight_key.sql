@@ -0,0 +1,13 @@
+-- A flight is identified by its IATA flight number, date and departure
+-- airport; polling updates that row in place. Keep the newest of any
+-- duplicates inserted before.
+
+ALTER TABLE flights ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE;
+
+DELETE FROM flights a USING flights b
+WHERE a.flight_iata = b.flight_iata
+  AND a.flight_date = b.flight_date
+  AND a.departure_iata = b.departure_iata
+  AND (a.created_at, a.ctid) < (b.created_at, b.ctid);
+
+CREATE UNIQUE INDEX flights_flight_key ON flights (flight_iata, flight_date, departure_iata);
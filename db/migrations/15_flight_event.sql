@@ -0,0 +1,13 @@
+CREATE TABLE flight_event (
+                            id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                            flight_id UUID NOT NULL REFERENCES flights(id) ON DELETE CASCADE,
+                            event_type varchar(20) NOT NULL CHECK (
+                              event_type IN ('status', 'delay', 'gate', 'terminal', 'runway', 'diversion')
+                            ),
+                            field varchar(50) NOT NULL,
+                            old_value text,
+                            new_value text,
+                            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE INDEX flight_event_flight_idx ON flight_event (flight_id, created_at);
@@ -0,0 +1,14 @@
+CREATE TABLE job_retry (
+                         id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                         job varchar(255) NOT NULL,
+                         status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dead')),
+                         attempts INT NOT NULL DEFAULT 0,
+                         next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW (),
+                         last_error text,
+                         created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW (),
+                         updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+-- at most one pending retry per job
+CREATE UNIQUE INDEX job_retry_pending_key ON job_retry (job) WHERE status = 'pending';
+CREATE INDEX job_retry_next_attempt_idx ON job_retry (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,27 @@
+CREATE TABLE seed_checkpoint (
+                               dataset varchar(255) PRIMARY KEY,
+                               next_offset INT NOT NULL DEFAULT 0,
+                               total INT,
+                               completed_at TIMESTAMP WITH TIME ZONE,
+                               updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+-- Tables seeded before checkpoints were recorded count as complete, as
+-- they did when a non-empty table was taken as seeded.
+INSERT INTO seed_checkpoint (dataset, completed_at)
+SELECT dataset, NOW ()
+FROM (VALUES
+  ('airline', EXISTS (SELECT 1 FROM airline)),
+  ('aircraft', EXISTS (SELECT 1 FROM aircraft)),
+  ('tax', EXISTS (SELECT 1 FROM tax)),
+  ('airplane', EXISTS (SELECT 1 FROM airplane)),
+  ('airport', EXISTS (SELECT 1 FROM airport)),
+  ('country', EXISTS (SELECT 1 FROM country)),
+  ('city', EXISTS (SELECT 1 FROM city)),
+  ('route', EXISTS (SELECT 1 FROM route)),
+  ('flights', EXISTS (SELECT 1 FROM flights)),
+  ('runway', EXISTS (SELECT 1 FROM runway)),
+  ('airport_frequency', EXISTS (SELECT 1 FROM airport_frequency)),
+  ('navaid', EXISTS (SELECT 1 FROM navaid))
+) AS seeded (dataset, has_rows)
+WHERE has_rows;
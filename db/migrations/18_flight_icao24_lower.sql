@@ -0,0 +1,4 @@
+-- Live positions are matched on lower(aircraft_icao24), which the plain
+-- index cannot serve.
+DROP INDEX IF EXISTS flights_aircraft_icao24_idx;
+CREATE INDEX flights_aircraft_icao24_lower_idx ON flights (lower(aircraft_icao24));
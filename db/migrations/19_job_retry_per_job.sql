@@ -0,0 +1,9 @@
+-- A job has one retry row, pending or dead, reused by every failure and
+-- cleared by a successful run. Keep the most recently updated row of any
+-- job queued more than once.
+DELETE FROM job_retry a USING job_retry b
+WHERE a.job = b.job
+  AND (a.updated_at, a.id) < (b.updated_at, b.id);
+
+DROP INDEX job_retry_pending_key;
+CREATE UNIQUE INDEX job_retry_job_key ON job_retry (job);
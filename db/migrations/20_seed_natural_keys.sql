@@ -0,0 +1,30 @@
+-- Seeding skips rows already stored, which needs a unique key on every
+-- seeded table. Runways, frequencies and navaids have their OurAirports id;
+-- a route is its airline and airports. Keep one of any duplicates stored
+-- by an interrupted seed.
+
+DELETE FROM route a USING route b
+WHERE coalesce(a.airline_code, '') = coalesce(b.airline_code, '')
+  AND coalesce(a.departure_iata, '') = coalesce(b.departure_iata, '')
+  AND coalesce(a.arrival_iata, '') = coalesce(b.arrival_iata, '')
+  AND a.ctid > b.ctid;
+
+-- NULLS NOT DISTINCT needs Postgres 15, so a missing code is coalesced to
+-- '' instead and a route without an airline code is still stored once
+CREATE UNIQUE INDEX route_natural_key
+    ON route ((coalesce(airline_code, '')), (coalesce(departure_iata, '')), (coalesce(arrival_iata, '')));
+
+DELETE FROM runway a USING runway b
+WHERE a.runway_id = b.runway_id AND a.ctid > b.ctid;
+
+CREATE UNIQUE INDEX runway_runway_id_key ON runway (runway_id);
+
+DELETE FROM airport_frequency a USING airport_frequency b
+WHERE a.frequency_id = b.frequency_id AND a.ctid > b.ctid;
+
+CREATE UNIQUE INDEX airport_frequency_frequency_id_key ON airport_frequency (frequency_id);
+
+DELETE FROM navaid a USING navaid b
+WHERE a.navaid_id = b.navaid_id AND a.ctid > b.ctid;
+
+CREATE UNIQUE INDEX navaid_navaid_id_key ON navaid (navaid_id);
@@ -0,0 +1,5 @@
+-- OurAirports links runways, frequencies and navaids to an airport by its
+-- ident, which is not always the ICAO code.
+ALTER TABLE airport ADD COLUMN ident varchar(10);
+
+create index on "airport" (ident);
@@ -0,0 +1 @@
+ALTER TABLE job_run ADD COLUMN rejected INT NOT NULL DEFAULT 0;
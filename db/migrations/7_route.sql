@@ -0,0 +1,17 @@
+CREATE TABLE route (
+                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                     airline_code varchar(10),
+                     airline_id INT DEFAULT 0,
+                     departure_iata varchar(10),
+                     departure_airport_id INT DEFAULT 0,
+                     arrival_iata varchar(10),
+                     arrival_airport_id INT DEFAULT 0,
+                     codeshare bool DEFAULT false,
+                     stops INT DEFAULT 0,
+                     equipment varchar(255),
+                     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+create index on "route" (id);
+create index on "route" (departure_iata);
+create index on "route" (arrival_iata);
@@ -0,0 +1,58 @@
+CREATE TABLE runway (
+                      id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                      runway_id INT DEFAULT 0,
+                      airport_ident varchar(10),
+                      length_ft INT,
+                      width_ft INT,
+                      surface varchar(255),
+                      lighted bool DEFAULT false,
+                      closed bool DEFAULT false,
+                      le_ident varchar(10),
+                      le_latitude float8,
+                      le_longitude float8,
+                      le_elevation_ft INT,
+                      le_heading_deg float8,
+                      le_displaced_threshold_ft INT,
+                      he_ident varchar(10),
+                      he_latitude float8,
+                      he_longitude float8,
+                      he_elevation_ft INT,
+                      he_heading_deg float8,
+                      he_displaced_threshold_ft INT,
+                      created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE TABLE airport_frequency (
+                                 id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                 frequency_id INT DEFAULT 0,
+                                 airport_ident varchar(10),
+                                 type varchar(255),
+                                 description varchar(255),
+                                 frequency_mhz float8,
+                                 created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE TABLE navaid (
+                      id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                      navaid_id INT DEFAULT 0,
+                      ident varchar(10),
+                      navaid_name varchar(255),
+                      type varchar(20),
+                      frequency_khz INT,
+                      latitude float8,
+                      longitude float8,
+                      elevation_ft INT,
+                      iso_country varchar(2),
+                      dme_frequency_khz INT,
+                      dme_channel varchar(10),
+                      magnetic_variation_deg float8,
+                      usage_type varchar(20),
+                      power varchar(20),
+                      associated_airport varchar(10),
+                      created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+create index on "runway" (airport_ident);
+create index on "airport_frequency" (airport_ident);
+create index on "navaid" (associated_airport);
+create index on "airport" (icao_code);
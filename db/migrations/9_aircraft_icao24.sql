@@ -0,0 +1,3 @@
+ALTER TABLE flights RENAME COLUMN aircraft_icao25 TO aircraft_icao24;
+
+create index on "flights" (aircraft_icao24);
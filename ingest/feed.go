@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// runFeed connects to the TCP feed at addr and hands the connection to read,
+// reconnecting after reconnectDelay until ctx is done.
+func runFeed(ctx context.Context, name, addr string, reconnectDelay time.Duration, read func(context.Context, io.Reader) error) error {
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			slog.Info("Connected to feed", "feed", name, "addr", addr)
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			err = read(ctx, conn)
+			stop()
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Warn("Feed disconnected, reconnecting", "feed", name, "addr", addr, "error", err, "delay", reconnectDelay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/FACorreiaa/go-ollama/ingest/modes"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Raw Mode-S frame formats, as served by dump1090 on ports 30002 and 30005.
+const (
+	FormatAVR   = "avr"
+	FormatBeast = "beast"
+)
+
+// pruneEvery is how many frames are read between decoder prunes.
+const pruneEvery = 10000
+
+// ReadModeS decodes the AVR or Beast frames of r and applies the DF17/DF18
+// messages to tracker until r is exhausted or ctx is done.
+func ReadModeS(ctx context.Context, r io.Reader, format string, decoder *modes.Decoder, tracker *Tracker) error {
+	next, err := frameReader(r, format)
+	if err != nil {
+		return err
+	}
+
+	frames, rejected := 0, 0
+	defer func() {
+		if rejected > 0 {
+			slog.Warn("Rejected Mode-S frames", "count", rejected)
+		}
+	}()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		frame, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if frame == nil {
+			rejected++
+			continue
+		}
+
+		frames++
+		if frames%pruneEvery == 0 {
+			decoder.Prune(time.Now().Add(-staleAfter))
+		}
+
+		m, err := decoder.Decode(frame, time.Now())
+		if errors.Is(err, modes.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			rejected++
+			slog.Debug("Skipping Mode-S frame", "error", err, "frame", fmt.Sprintf("%x", frame))
+			continue
+		}
+		tracker.Apply(modeSUpdate(m))
+	}
+}
+
+// frameReader returns a function yielding the next frame of r, or a nil
+// frame for an unreadable AVR line.
+func frameReader(r io.Reader, format string) (func() ([]byte, error), error) {
+	switch format {
+	case FormatAVR:
+		scanner := bufio.NewScanner(r)
+		return func() ([]byte, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			frame, err := modes.ParseAVR(scanner.Text())
+			if err != nil {
+				slog.Debug("Skipping AVR line", "error", err)
+				return nil, nil
+			}
+			return frame, nil
+		}, nil
+	case FormatBeast:
+		return modes.NewBeastReader(r).Next, nil
+	default:
+		return nil, fmt.Errorf("unknown Mode-S format %q", format)
+	}
+}
+
+func modeSUpdate(m modes.Message) Update {
+	u := Update{
+		Source:    SourceModeS,
+		ICAO24:    m.ICAO24,
+		Callsign:  m.Callsign,
+		Latitude:  m.Latitude,
+		Longitude: m.Longitude,
+		Track:     m.Track,
+		OnGround:  m.OnGround,
+	}
+	if m.Altitude != nil {
+		altitude := feetToMetres(float64(*m.Altitude))
+		u.Altitude = &altitude
+	}
+	if m.Speed != nil {
+		speed := knotsToKmh(*m.Speed)
+		u.Speed = &speed
+	}
+	if m.VerticalRate != nil {
+		rate := feetPerMinuteToKmh(float64(*m.VerticalRate))
+		u.VerticalRate = &rate
+	}
+	return u
+}
+
+// ReadModeSFile replays a recorded AVR or Beast capture into tracker.
+func ReadModeSFile(ctx context.Context, path, format string, decoder *modes.Decoder, tracker *Tracker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	return ReadModeS(ctx, f, format, decoder, tracker)
+}
+
+// RunModeS reads the raw Mode-S feed at addr into tracker until ctx is done,
+// reconnecting after reconnectDelay whenever the connection drops.
+func RunModeS(ctx context.Context, addr, format string, reconnectDelay time.Duration, decoder *modes.Decoder, tracker *Tracker) error {
+	return runFeed(ctx, "Mode-S", addr, reconnectDelay, func(ctx context.Context, r io.Reader) error {
+		return ReadModeS(ctx, r, format, decoder, tracker)
+	})
+}
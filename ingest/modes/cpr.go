@@ -0,0 +1,208 @@
+package modes
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	cprMax = 1 << 17
+	// globalPairWindow is how far apart an even and odd frame may be for a
+	// global decode.
+	globalPairWindow = 10 * time.Second
+	// localReferenceAge is how long a decoded position stays usable as the
+	// reference for local decoding.
+	localReferenceAge = time.Minute
+	// maxReceiverRange is how far from the receiver a local decode against
+	// its position is trusted, in degrees of latitude (about 180 NM).
+	maxReceiverRange = 3.0
+)
+
+type cprFrame struct {
+	odd      bool
+	lat, lon uint32
+	time     time.Time
+}
+
+type aircraftState struct {
+	even, odd    *cprFrame
+	lat, lon     float64
+	positionTime time.Time
+}
+
+// Position is a latitude and longitude in degrees.
+type Position struct {
+	Latitude, Longitude float64
+}
+
+// Decoder decodes frames into messages, pairing CPR position frames per
+// aircraft. It is safe for concurrent use.
+type Decoder struct {
+	mu        sync.Mutex
+	aircraft  map[string]*aircraftState
+	reference *Position
+}
+
+// NewDecoder returns a decoder. When the receiver's position is known,
+// passing it as reference lets single position frames be decoded locally
+// before an even/odd pair has been seen.
+func NewDecoder(reference *Position) *Decoder {
+	return &Decoder{aircraft: make(map[string]*aircraftState), reference: reference}
+}
+
+// Decode decodes one Mode-S frame received at t.
+func (d *Decoder) Decode(frame []byte, t time.Time) (Message, error) {
+	m, cpr, err := parse(frame)
+	if err != nil || cpr == nil {
+		return m, err
+	}
+	cpr.time = t
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.aircraft[m.ICAO24]
+	if !ok {
+		state = &aircraftState{}
+		d.aircraft[m.ICAO24] = state
+	}
+	if cpr.odd {
+		state.odd = cpr
+	} else {
+		state.even = cpr
+	}
+
+	lat, lon, ok := d.position(state, cpr)
+	if !ok {
+		return m, nil
+	}
+	state.lat, state.lon, state.positionTime = lat, lon, t
+	m.Latitude, m.Longitude = &lat, &lon
+	return m, nil
+}
+
+func (d *Decoder) position(state *aircraftState, latest *cprFrame) (float64, float64, bool) {
+	if state.even != nil && state.odd != nil && absDuration(state.even.time.Sub(state.odd.time)) <= globalPairWindow {
+		if lat, lon, ok := globalCPR(state.even, state.odd, latest.odd); ok {
+			return lat, lon, true
+		}
+	}
+	if !state.positionTime.IsZero() && latest.time.Sub(state.positionTime) <= localReferenceAge {
+		return localCPR(latest, state.lat, state.lon)
+	}
+	if d.reference != nil {
+		lat, lon, ok := localCPR(latest, d.reference.Latitude, d.reference.Longitude)
+		if ok && math.Abs(lat-d.reference.Latitude) <= maxReceiverRange {
+			return lat, lon, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Prune forgets aircraft without a frame since before.
+func (d *Decoder) Prune(before time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for icao24, state := range d.aircraft {
+		last := state.positionTime
+		for _, f := range []*cprFrame{state.even, state.odd} {
+			if f != nil && f.time.After(last) {
+				last = f.time
+			}
+		}
+		if last.Before(before) {
+			delete(d.aircraft, icao24)
+		}
+	}
+}
+
+// globalCPR decodes an airborne position from an even and an odd frame,
+// using the latitude of the most recent one.
+func globalCPR(even, odd *cprFrame, oddLatest bool) (float64, float64, bool) {
+	latEven := float64(even.lat) / cprMax
+	lonEven := float64(even.lon) / cprMax
+	latOdd := float64(odd.lat) / cprMax
+	lonOdd := float64(odd.lon) / cprMax
+
+	j := math.Floor(59*latEven - 60*latOdd + 0.5)
+	rlatEven := 360.0 / 60 * (mod(j, 60) + latEven)
+	rlatOdd := 360.0 / 59 * (mod(j, 59) + latOdd)
+	if rlatEven >= 270 {
+		rlatEven -= 360
+	}
+	if rlatOdd >= 270 {
+		rlatOdd -= 360
+	}
+	// both frames must lie in the same longitude zone
+	if nl(rlatEven) != nl(rlatOdd) {
+		return 0, 0, false
+	}
+
+	lat, lonCpr, i := rlatEven, lonEven, 0.0
+	if oddLatest {
+		lat, lonCpr, i = rlatOdd, lonOdd, 1
+	}
+	zones := nl(lat)
+	ni := math.Max(zones-i, 1)
+	m := math.Floor(lonEven*(zones-1) - lonOdd*zones + 0.5)
+	lon := 360 / ni * (mod(m, ni) + lonCpr)
+	if lon >= 180 {
+		lon -= 360
+	}
+	return lat, lon, true
+}
+
+// localCPR decodes a single frame relative to a position within 180 NM.
+func localCPR(f *cprFrame, refLat, refLon float64) (float64, float64, bool) {
+	i := 0.0
+	if f.odd {
+		i = 1
+	}
+	latCpr := float64(f.lat) / cprMax
+	lonCpr := float64(f.lon) / cprMax
+
+	dLat := 360 / (60 - i)
+	j := math.Floor(refLat/dLat) + math.Floor(mod(refLat, dLat)/dLat-latCpr+0.5)
+	lat := dLat * (j + latCpr)
+	if lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+
+	dLon := 360 / math.Max(nl(lat)-i, 1)
+	m := math.Floor(refLon/dLon) + math.Floor(mod(refLon, dLon)/dLon-lonCpr+0.5)
+	lon := dLon * (m + lonCpr)
+	if lon >= 180 {
+		lon -= 360
+	}
+	return lat, lon, true
+}
+
+// nl is the number of longitude zones at lat.
+func nl(lat float64) float64 {
+	lat = math.Abs(lat)
+	switch {
+	case lat == 0:
+		return 59
+	case lat == 87:
+		return 2
+	case lat > 87:
+		return 1
+	}
+	const nz = 15
+	a := 1 - math.Cos(math.Pi/(2*nz))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return math.Floor(2 * math.Pi / math.Acos(1-a/b))
+}
+
+func mod(x, y float64) float64 {
+	return x - y*math.Floor(x/y)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,125 @@
+package modes
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseAVR reads one line of AVR text output, "*8D4840D6202CC371C32CE0576098;",
+// optionally prefixed with "@" and a 12 digit MLAT timestamp.
+func ParseAVR(line string) ([]byte, error) {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "*"):
+		line = line[1:]
+	case strings.HasPrefix(line, "@") && len(line) > 13:
+		line = line[13:]
+	default:
+		return nil, fmt.Errorf("invalid AVR line %q", line)
+	}
+	line = strings.TrimSuffix(line, ";")
+
+	frame, err := hex.DecodeString(line)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVR line: %w", err)
+	}
+	return frame, nil
+}
+
+const beastEscape = 0x1a
+
+// BeastReader splits a Beast binary stream into Mode-S frames.
+type BeastReader struct {
+	r *bufio.Reader
+	// pending is the type byte of a message found inside a truncated one
+	pending byte
+}
+
+func NewBeastReader(r io.Reader) *BeastReader {
+	return &BeastReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next Mode-S frame, skipping Mode A/C and status messages.
+func (b *BeastReader) Next() ([]byte, error) {
+	for {
+		kind, err := b.sync()
+		if err != nil {
+			return nil, err
+		}
+
+		var size int
+		switch kind {
+		case '1':
+			size = 2
+		case '2':
+			size = 7
+		case '3':
+			size = 14
+		case '4':
+			size = 14
+		default:
+			// a doubled escape or garbage; keep searching
+			continue
+		}
+
+		// 6 byte timestamp and a signal level byte precede the payload
+		data, err := b.read(6 + 1 + size)
+		if errors.Is(err, errBeastResync) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind == '2' || kind == '3' {
+			return data[7:], nil
+		}
+	}
+}
+
+// sync skips to the next escape and returns the message type after it.
+func (b *BeastReader) sync() (byte, error) {
+	if b.pending != 0 {
+		kind := b.pending
+		b.pending = 0
+		return kind, nil
+	}
+	for {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == beastEscape {
+			return b.r.ReadByte()
+		}
+	}
+}
+
+var errBeastResync = errors.New("unexpected escape in Beast message")
+
+// read reads n unescaped bytes. A lone escape means the message was cut
+// short by the start of the next one, whose type is kept for sync.
+func (b *BeastReader) read(n int) ([]byte, error) {
+	data := make([]byte, 0, n)
+	for len(data) < n {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == beastEscape {
+			next, err := b.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if next != beastEscape {
+				b.pending = next
+				return nil, errBeastResync
+			}
+		}
+		data = append(data, c)
+	}
+	return data, nil
+}
@@ -0,0 +1,198 @@
+// Package modes decodes DF17/DF18 Mode-S extended squitter (ADS-B) frames:
+// identification, airborne position, altitude and velocity. Positions need
+// CPR decoding across frames, so decoding goes through a stateful Decoder.
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrUnsupported is returned for valid frames that are not DF17/DF18
+	// extended squitters, e.g. short Mode-S replies.
+	ErrUnsupported = errors.New("not an extended squitter")
+	ErrChecksum    = errors.New("checksum mismatch")
+)
+
+// Message is what a single frame says about an aircraft. Nil fields were
+// not part of the frame. Units are the ones ADS-B uses: feet, knots and feet
+// per minute.
+type Message struct {
+	DF           int
+	TypeCode     int
+	ICAO24       string
+	Callsign     string
+	Altitude     *int
+	Latitude     *float64
+	Longitude    *float64
+	Speed        *float64
+	Track        *float64
+	VerticalRate *int
+	OnGround     *bool
+}
+
+const longFrameLen = 14
+
+const callsignCharset = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+var crcTable = func() (table [256]uint32) {
+	const poly = 0xFFF409
+	for i := range table {
+		c := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			if c&0x800000 != 0 {
+				c = (c << 1) ^ poly
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c & 0xFFFFFF
+	}
+	return table
+}()
+
+// checksum is the Mode-S CRC-24 of data.
+func checksum(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = ((crc << 8) ^ crcTable[byte(crc>>16)^b]) & 0xFFFFFF
+	}
+	return crc
+}
+
+// bits returns n bits of data starting at the 1-based bit first, the
+// numbering used by the ADS-B specification.
+func bits(data []byte, first, n int) uint32 {
+	var v uint32
+	for i := first - 1; i < first-1+n; i++ {
+		v = v<<1 | uint32(data[i/8]>>(7-i%8))&1
+	}
+	return v
+}
+
+// parse decodes everything but the CPR position of an extended squitter.
+func parse(frame []byte) (Message, *cprFrame, error) {
+	if len(frame) == 0 {
+		return Message{}, nil, ErrUnsupported
+	}
+	df := int(frame[0] >> 3)
+	if df != 17 && df != 18 {
+		return Message{}, nil, ErrUnsupported
+	}
+	if len(frame) != longFrameLen {
+		return Message{}, nil, fmt.Errorf("DF%d frame has %d bytes, want %d", df, len(frame), longFrameLen)
+	}
+	if checksum(frame[:11]) != bits(frame, 89, 24) {
+		return Message{}, nil, ErrChecksum
+	}
+	// DF18 with control field above 1 carries TIS-B and ADS-R formats
+	if df == 18 && frame[0]&0x07 > 1 {
+		return Message{}, nil, ErrUnsupported
+	}
+
+	me := frame[4:11]
+	m := Message{
+		DF:       df,
+		TypeCode: int(bits(me, 1, 5)),
+		ICAO24:   fmt.Sprintf("%06x", bits(frame, 9, 24)),
+	}
+
+	switch tc := m.TypeCode; {
+	case tc >= 1 && tc <= 4:
+		m.Callsign = callsign(me)
+	case tc >= 5 && tc <= 8:
+		onGround := true
+		m.OnGround = &onGround
+	case tc >= 9 && tc <= 18, tc >= 20 && tc <= 22:
+		onGround := false
+		m.OnGround = &onGround
+		m.Altitude = altitude(me, tc)
+		return m, &cprFrame{odd: bits(me, 22, 1) == 1, lat: bits(me, 23, 17), lon: bits(me, 40, 17)}, nil
+	case tc == 19:
+		velocity(me, &m)
+	}
+	return m, nil, nil
+}
+
+func callsign(me []byte) string {
+	var b []byte
+	for i := 0; i < 8; i++ {
+		b = append(b, callsignCharset[bits(me, 9+6*i, 6)])
+	}
+	for len(b) > 0 && (b[len(b)-1] == ' ' || b[len(b)-1] == '#') {
+		b = b[:len(b)-1]
+	}
+	return string(b)
+}
+
+// altitude reads the 12 bit altitude field. Type codes 20 to 22 carry GNSS
+// height in metres; the others barometric altitude, of which only the 25 ft
+// encoding is supported.
+func altitude(me []byte, tc int) *int {
+	field := int(bits(me, 9, 12))
+	if field == 0 {
+		return nil
+	}
+	if tc >= 20 {
+		ft := int(math.Round(float64(field) * 3.28084))
+		return &ft
+	}
+	if field&0x10 == 0 {
+		return nil
+	}
+
+	n := (field&0xFE0)>>1 | field&0x0F
+	ft := n*25 - 1000
+	return &ft
+}
+
+// velocity reads the airborne velocity message: ground speed for subtypes 1
+// and 2, airspeed and heading for 3 and 4.
+func velocity(me []byte, m *Message) {
+	subtype := bits(me, 6, 3)
+	factor := 1.0
+	if subtype == 2 || subtype == 4 {
+		factor = 4
+	}
+
+	switch subtype {
+	case 1, 2:
+		ew, ns := bits(me, 15, 10), bits(me, 26, 10)
+		if ew == 0 || ns == 0 {
+			break
+		}
+		vew := float64(ew-1) * factor
+		vns := float64(ns-1) * factor
+		if bits(me, 14, 1) == 1 {
+			vew = -vew
+		}
+		if bits(me, 25, 1) == 1 {
+			vns = -vns
+		}
+
+		speed := math.Hypot(vew, vns)
+		track := math.Mod(math.Atan2(vew, vns)*180/math.Pi+360, 360)
+		m.Speed, m.Track = &speed, &track
+	case 3, 4:
+		if bits(me, 14, 1) == 1 {
+			heading := float64(bits(me, 15, 10)) * 360 / 1024
+			m.Track = &heading
+		}
+		if airspeed := bits(me, 26, 10); airspeed != 0 {
+			speed := float64(airspeed-1) * factor
+			m.Speed = &speed
+		}
+	default:
+		return
+	}
+
+	if rate := bits(me, 38, 9); rate != 0 {
+		fpm := int(rate-1) * 64
+		if bits(me, 37, 1) == 1 {
+			fpm = -fpm
+		}
+		m.VerticalRate = &fpm
+	}
+}
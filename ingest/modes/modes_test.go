@@ -0,0 +1,265 @@
+package modes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// The frames are the worked examples of "The 1090 Megahertz Riddle" by
+// Junzi Sun, https://mode-s.org/decode.
+
+func frame(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func near(got *float64, want, tolerance float64) bool {
+	return got != nil && math.Abs(*got-want) <= tolerance
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []string{
+		"8D4840D6202CC371C32CE0576098",
+		"8D40621D58C382D690C8AC2863A7",
+		"8D485020994409940838175B284F",
+	}
+	for _, tt := range tests {
+		f := frame(t, tt)
+		if got, want := checksum(f[:11]), bits(f, 89, 24); got != want {
+			t.Errorf("checksum(%s) = %06x, want %06x", tt, got, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		check func(t *testing.T, m Message)
+	}{
+		{
+			name:  "identification",
+			frame: "8D4840D6202CC371C32CE0576098",
+			check: func(t *testing.T, m Message) {
+				if m.DF != 17 || m.TypeCode != 4 || m.ICAO24 != "4840d6" || m.Callsign != "KLM1023" {
+					t.Errorf("got DF%d TC%d %s %q, want DF17 TC4 4840d6 \"KLM1023\"",
+						m.DF, m.TypeCode, m.ICAO24, m.Callsign)
+				}
+			},
+		},
+		{
+			name:  "airborne position",
+			frame: "8D40621D58C382D690C8AC2863A7",
+			check: func(t *testing.T, m Message) {
+				if m.TypeCode != 11 || m.Altitude == nil || *m.Altitude != 38000 {
+					t.Errorf("got TC%d altitude %v, want TC11 38000", m.TypeCode, m.Altitude)
+				}
+				if m.OnGround == nil || *m.OnGround {
+					t.Errorf("got on ground %v, want false", m.OnGround)
+				}
+				// a single frame without a reference has no position
+				if m.Latitude != nil || m.Longitude != nil {
+					t.Errorf("got a position from a single frame")
+				}
+			},
+		},
+		{
+			name:  "ground speed",
+			frame: "8D485020994409940838175B284F",
+			check: func(t *testing.T, m Message) {
+				if !near(m.Speed, 159.20, 0.01) || !near(m.Track, 182.88, 0.01) {
+					t.Errorf("got speed %v track %v, want 159.20 182.88", m.Speed, m.Track)
+				}
+				if m.VerticalRate == nil || *m.VerticalRate != -832 {
+					t.Errorf("got vertical rate %v, want -832", m.VerticalRate)
+				}
+			},
+		},
+		{
+			name:  "airspeed and heading",
+			frame: "8DA05F219B06B6AF189400CBC33F",
+			check: func(t *testing.T, m Message) {
+				if !near(m.Speed, 375, 0) || !near(m.Track, 243.98, 0.01) {
+					t.Errorf("got speed %v track %v, want 375 243.98", m.Speed, m.Track)
+				}
+				if m.VerticalRate == nil || *m.VerticalRate != -2304 {
+					t.Errorf("got vertical rate %v, want -2304", m.VerticalRate)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewDecoder(nil).Decode(frame(t, tt.frame), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  error
+	}{
+		{"bad checksum", "8D4840D6202CC371C32CE0576099", ErrChecksum},
+		{"flipped payload bit", "8D4840D6202CC371C32CE1576098", ErrChecksum},
+		{"short reply", "5D4840D6A1B2C3", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDecoder(nil).Decode(frame(t, tt.frame), time.Now()); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewDecoder(nil).Decode(frame(t, "8D4840D6202CC371C32CE05760"), time.Now()); err == nil {
+		t.Error("got no error for a truncated DF17 frame")
+	}
+}
+
+func TestDecodePosition(t *testing.T) {
+	const (
+		even = "8D40621D58C382D690C8AC2863A7"
+		odd  = "8D40621D58C386435CC412692AD6"
+	)
+	t0 := time.Unix(1457996400, 0)
+
+	tests := []struct {
+		name      string
+		reference *Position
+		frames    []string
+		wantLat   float64
+		wantLon   float64
+	}{
+		{
+			name:    "global, even latest",
+			frames:  []string{odd, even},
+			wantLat: 52.25720,
+			wantLon: 3.91937,
+		},
+		{
+			name:    "global, odd latest",
+			frames:  []string{even, odd},
+			wantLat: 52.26578,
+			wantLon: 3.93891,
+		},
+		{
+			name:      "local against the receiver",
+			reference: &Position{Latitude: 52.258, Longitude: 3.918},
+			frames:    []string{even},
+			wantLat:   52.25720,
+			wantLon:   3.91937,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(tt.reference)
+			var m Message
+			for i, f := range tt.frames {
+				var err error
+				if m, err = d.Decode(frame(t, f), t0.Add(time.Duration(i)*2*time.Second)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !near(m.Latitude, tt.wantLat, 1e-4) || !near(m.Longitude, tt.wantLon, 1e-4) {
+				t.Errorf("got %v, %v, want %v, %v", deref(m.Latitude), deref(m.Longitude), tt.wantLat, tt.wantLon)
+			}
+		})
+	}
+
+	// frames too far apart are not paired
+	d := NewDecoder(nil)
+	if _, err := d.Decode(frame(t, odd), t0); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.Decode(frame(t, even), t0.Add(globalPairWindow+time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Latitude != nil {
+		t.Errorf("got a position from frames %s apart", globalPairWindow+time.Second)
+	}
+}
+
+func deref(f *float64) any {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func TestParseAVR(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{line: "*8D4840D6202CC371C32CE0576098;", want: "8d4840d6202cc371c32ce0576098"},
+		{line: "@0123456789AB8D4840D6202CC371C32CE0576098;\n", want: "8d4840d6202cc371c32ce0576098"},
+		{line: "8D4840D6202CC371C32CE0576098", wantErr: true},
+		{line: "*8D48ZZ;", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAVR(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAVR(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("ParseAVR(%q) = %x, want %s", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestBeastReader(t *testing.T) {
+	long := frame(t, "8D4840D6202CC371C32CE0576098")
+	header := []byte{0, 0, 0, 0, 0, 0, 0x80}
+
+	var stream bytes.Buffer
+	// a Mode A/C message, skipped
+	stream.Write([]byte{beastEscape, '1', 0, 0, 0, 0, 0, 0, 0x80, 0x12, 0x34})
+	// a long frame whose payload contains an escaped 0x1a
+	stream.Write([]byte{beastEscape, '3'})
+	stream.Write(header)
+	escaped := append([]byte{}, long...)
+	escaped[5] = beastEscape
+	for _, b := range escaped {
+		stream.WriteByte(b)
+		if b == beastEscape {
+			stream.WriteByte(beastEscape)
+		}
+	}
+	// a long frame cut short by the next one
+	stream.Write([]byte{beastEscape, '3', 0, 0, beastEscape, '3'})
+	stream.Write(header)
+	stream.Write(long)
+
+	r := NewBeastReader(&stream)
+	for i, want := range [][]byte{escaped, long} {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("frame %d = %x, want %x", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("got a frame past the end of the stream")
+	}
+}
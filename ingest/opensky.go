@@ -0,0 +1,214 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"time"
+)
+
+// openSkyStates is the body of OpenSky's /states/all. Each state is an
+// array, see https://openskynetwork.github.io/opensky-api/rest.html
+type openSkyStates struct {
+	Time   int64               `json:"time"`
+	States [][]json.RawMessage `json:"states"`
+}
+
+// State vector indices used here.
+const (
+	osICAO24 = iota
+	osCallsign
+	osOriginCountry
+	osTimePosition
+	osLastContact
+	osLongitude
+	osLatitude
+	osBaroAltitude
+	osOnGround
+	osVelocity
+	osTrueTrack
+	osVerticalRate
+	osSensors
+	osGeoAltitude
+	osSquawk
+	osFields
+)
+
+// ParseOpenSkyStates decodes a /states/all response. Altitudes arrive in
+// metres and speeds in m/s, which are converted to km/h. Malformed states
+// are skipped and counted in skipped.
+func ParseOpenSkyStates(r io.Reader) (updates []Update, skipped int, err error) {
+	var body openSkyStates
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("error decoding state vectors: %w", err)
+	}
+
+	updates = make([]Update, 0, len(body.States))
+	for i, state := range body.States {
+		u, err := openSkyUpdate(state, body.Time)
+		if err != nil {
+			skipped++
+			slog.Debug("Skipping OpenSky state", "index", i, "error", err)
+			continue
+		}
+		updates = append(updates, u)
+	}
+	return updates, skipped, nil
+}
+
+func openSkyUpdate(state []json.RawMessage, fallback int64) (Update, error) {
+	if len(state) < osFields {
+		return Update{}, fmt.Errorf("expected %d fields, got %d", osFields, len(state))
+	}
+
+	var (
+		icao24, callsign, squawk          *string
+		timePosition, lastContact         *int64
+		latitude, longitude, baroAltitude *float64
+		velocity, verticalRate            *float64
+		onGround                          *bool
+		u                                 = Update{Source: SourceOpenSky}
+	)
+	fields := map[int]any{
+		osICAO24:       &icao24,
+		osCallsign:     &callsign,
+		osTimePosition: &timePosition,
+		osLastContact:  &lastContact,
+		osLongitude:    &longitude,
+		osLatitude:     &latitude,
+		osBaroAltitude: &baroAltitude,
+		osOnGround:     &onGround,
+		osVelocity:     &velocity,
+		osTrueTrack:    &u.Track,
+		osVerticalRate: &verticalRate,
+		osSquawk:       &squawk,
+	}
+	for i, field := range fields {
+		if err := json.Unmarshal(state[i], field); err != nil {
+			return Update{}, fmt.Errorf("field %d: %w", i, err)
+		}
+	}
+	if icao24 == nil {
+		return Update{}, fmt.Errorf("missing icao24")
+	}
+
+	u.ICAO24 = *icao24
+	u.Latitude, u.Longitude = latitude, longitude
+	u.OnGround = onGround
+	if callsign != nil {
+		u.Callsign = *callsign
+	}
+	if squawk != nil {
+		u.Squawk = *squawk
+	}
+	switch {
+	case timePosition != nil:
+		u.Time = time.Unix(*timePosition, 0)
+	case lastContact != nil:
+		u.Time = time.Unix(*lastContact, 0)
+	default:
+		u.Time = time.Unix(fallback, 0)
+	}
+	if baroAltitude != nil {
+		altitude := int(math.Round(*baroAltitude))
+		u.Altitude = &altitude
+	}
+	if velocity != nil {
+		speed := int(math.Round(*velocity * 3.6))
+		u.Speed = &speed
+	}
+	if verticalRate != nil {
+		rate := int(math.Round(*verticalRate * 3.6))
+		u.VerticalRate = &rate
+	}
+	return u, nil
+}
+
+// ReadOpenSkyFile applies a saved /states/all response to tracker.
+func ReadOpenSkyFile(path string, tracker *Tracker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	updates, skipped, err := ParseOpenSkyStates(f)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped malformed OpenSky states", "path", path, "skipped", skipped, "read", len(updates))
+	}
+	for _, u := range updates {
+		tracker.Apply(u)
+	}
+	return nil
+}
+
+// OpenSkyPoller fetches state vectors from an OpenSky compatible endpoint.
+type OpenSkyPoller struct {
+	url                string
+	username, password string
+	httpClient         *http.Client
+}
+
+func NewOpenSkyPoller(url, username, password string, httpClient *http.Client) *OpenSkyPoller {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OpenSkyPoller{url: url, username: username, password: password, httpClient: httpClient}
+}
+
+// Poll fetches the state vectors once.
+func (p *OpenSkyPoller) Poll(ctx context.Context) ([]Update, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenSky request failed with status %s", res.Status)
+	}
+	updates, skipped, err := ParseOpenSkyStates(res.Body)
+	if skipped > 0 {
+		slog.Warn("Skipped malformed OpenSky states", "url", p.url, "skipped", skipped, "read", len(updates))
+	}
+	return updates, err
+}
+
+// Run polls every interval and applies the state vectors to tracker until
+// ctx is done.
+func (p *OpenSkyPoller) Run(ctx context.Context, interval time.Duration, tracker *Tracker) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		updates, err := p.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("Error polling OpenSky states", "url", p.url, "error", err)
+		}
+		for _, u := range updates {
+			tracker.Apply(u)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
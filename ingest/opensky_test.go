@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOpenSkyStates(t *testing.T) {
+	const body = `{
+		"time": 1700000000,
+		"states": [
+			["4ca2d6", "RYR123  ", "Ireland", 1699999990, 1699999995, -6.2603, 53.3498, 10668.0, false,
+				231.5, 90.0, -5.2, null, 10700.0, "7000", false, 0],
+			["3c6444", null, "Germany", null, 1699999980, null, null, null, true,
+				0.0, null, null, null, null, null, false, 0],
+			["400ae7", "BAW1", "United Kingdom", null, null, 1.0, 2.0, "high", false,
+				100.0, 10.0, 0.0, null, null, null, false, 0],
+			[null, "NOHEX", "Nowhere", null, null, 1.0, 2.0, 1000.0, false,
+				100.0, 10.0, 0.0, null, null, null, false, 0],
+			["abc123", "SHORT"]
+		]
+	}`
+
+	updates, skipped, err := ParseOpenSkyStates(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 3 {
+		t.Errorf("got %d skipped states, want 3", skipped)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+
+	airborne := updates[0]
+	if airborne.Source != SourceOpenSky || airborne.ICAO24 != "4ca2d6" || airborne.Callsign != "RYR123  " ||
+		airborne.Squawk != "7000" {
+		t.Errorf("got %+v", airborne)
+	}
+	if !airborne.Time.Equal(time.Unix(1699999990, 0)) {
+		t.Errorf("got time %s, want the position time", airborne.Time)
+	}
+	checkPtr(t, "latitude", airborne.Latitude, ptr(53.3498))
+	checkPtr(t, "longitude", airborne.Longitude, ptr(-6.2603))
+	checkPtr(t, "altitude", airborne.Altitude, ptr(10668))
+	checkPtr(t, "speed", airborne.Speed, ptr(833))
+	checkPtr(t, "track", airborne.Track, ptr(90.0))
+	checkPtr(t, "vertical rate", airborne.VerticalRate, ptr(-19))
+	checkPtr(t, "on ground", airborne.OnGround, ptr(false))
+
+	ground := updates[1]
+	if !ground.Time.Equal(time.Unix(1699999980, 0)) {
+		t.Errorf("got time %s, want the last contact", ground.Time)
+	}
+	if ground.Latitude != nil || ground.Altitude != nil || ground.Callsign != "" {
+		t.Errorf("got %+v, want no position, altitude or callsign", ground)
+	}
+	checkPtr(t, "on ground", ground.OnGround, ptr(true))
+}
+
+func TestParseOpenSkyStatesInvalidBody(t *testing.T) {
+	if _, _, err := ParseOpenSkyStates(strings.NewReader(`{"states": {}}`)); err == nil {
+		t.Error("got no error for a malformed body")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
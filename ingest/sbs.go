@@ -0,0 +1,176 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotTransmission is returned by ParseSBS for the SEL, ID, AIR, STA and
+// CLK lines, which carry no aircraft data.
+var ErrNotTransmission = errors.New("not an SBS transmission message")
+
+// SBS-1 (BaseStation) field positions, see
+// http://woodair.net/sbs/article/barebones42_socket_data.htm
+const (
+	sbsMessageType = iota
+	sbsTransmissionType
+	sbsSessionID
+	sbsAircraftID
+	sbsHexIdent
+	sbsFlightID
+	sbsDateGenerated
+	sbsTimeGenerated
+	sbsDateLogged
+	sbsTimeLogged
+	sbsCallsign
+	sbsAltitude
+	sbsGroundSpeed
+	sbsTrack
+	sbsLatitude
+	sbsLongitude
+	sbsVerticalRate
+	sbsSquawk
+	sbsAlert
+	sbsEmergency
+	sbsSPI
+	sbsIsOnGround
+	sbsFields
+)
+
+// ParseSBS parses one MSG line (transmission types 1 to 8) of a BaseStation
+// feed. Altitude, ground speed and vertical rate arrive in feet, knots and
+// feet per minute and are converted to the units of structs.Live.
+func ParseSBS(line string) (Update, error) {
+	fields := strings.Split(strings.TrimRight(line, "\r\n"), ",")
+	if fields[sbsMessageType] != "MSG" {
+		return Update{}, ErrNotTransmission
+	}
+	if len(fields) < sbsFields {
+		return Update{}, fmt.Errorf("expected %d fields, got %d", sbsFields, len(fields))
+	}
+
+	transmission, err := strconv.Atoi(fields[sbsTransmissionType])
+	if err != nil || transmission < 1 || transmission > 8 {
+		return Update{}, fmt.Errorf("invalid transmission type %q", fields[sbsTransmissionType])
+	}
+
+	u := Update{
+		Source:   SourceSBS,
+		ICAO24:   fields[sbsHexIdent],
+		Time:     sbsTime(fields[sbsDateGenerated], fields[sbsTimeGenerated]),
+		Callsign: fields[sbsCallsign],
+		Squawk:   fields[sbsSquawk],
+	}
+	if len(u.ICAO24) != 6 {
+		return Update{}, fmt.Errorf("invalid hex ident %q", u.ICAO24)
+	}
+
+	if u.Latitude, err = optionalFloat(fields[sbsLatitude]); err != nil {
+		return Update{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	if u.Longitude, err = optionalFloat(fields[sbsLongitude]); err != nil {
+		return Update{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+	if u.Track, err = optionalFloat(fields[sbsTrack]); err != nil {
+		return Update{}, fmt.Errorf("invalid track: %w", err)
+	}
+	if u.Altitude, err = optionalConverted(fields[sbsAltitude], feetToMetres); err != nil {
+		return Update{}, fmt.Errorf("invalid altitude: %w", err)
+	}
+	if u.Speed, err = optionalConverted(fields[sbsGroundSpeed], knotsToKmh); err != nil {
+		return Update{}, fmt.Errorf("invalid ground speed: %w", err)
+	}
+	if u.VerticalRate, err = optionalConverted(fields[sbsVerticalRate], feetPerMinuteToKmh); err != nil {
+		return Update{}, fmt.Errorf("invalid vertical rate: %w", err)
+	}
+	if flag := fields[sbsIsOnGround]; flag != "" {
+		// dump1090 writes -1 for true, other feeders 1
+		onGround := flag != "0"
+		u.OnGround = &onGround
+	}
+
+	return u, nil
+}
+
+// sbsTime reads the generated timestamp, which feeders write in local time.
+func sbsTime(date, clock string) time.Time {
+	t, err := time.ParseInLocation("2006/01/02 15:04:05.000", date+" "+clock, time.Local)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+func optionalFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func optionalConverted(s string, convert func(float64) int) (*int, error) {
+	f, err := optionalFloat(s)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	n := convert(*f)
+	return &n, nil
+}
+
+// ReadSBS applies every MSG line of r to tracker until r is exhausted or ctx
+// is done. Malformed lines are skipped.
+func ReadSBS(ctx context.Context, r io.Reader, tracker *Tracker) error {
+	scanner := bufio.NewScanner(r)
+	skipped := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		u, err := ParseSBS(scanner.Text())
+		if errors.Is(err, ErrNotTransmission) {
+			continue
+		}
+		if err != nil {
+			skipped++
+			slog.Debug("Skipping SBS line", "error", err, "line", scanner.Text())
+			continue
+		}
+		tracker.Apply(u)
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped malformed SBS lines", "count", skipped)
+	}
+	return scanner.Err()
+}
+
+// ReadSBSFile replays a recorded SBS log into tracker.
+func ReadSBSFile(ctx context.Context, path string, tracker *Tracker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	return ReadSBS(ctx, f, tracker)
+}
+
+// RunSBS reads the BaseStation feed at addr into tracker until ctx is done,
+// reconnecting after reconnectDelay whenever the connection drops.
+func RunSBS(ctx context.Context, addr string, reconnectDelay time.Duration, tracker *Tracker) error {
+	return runFeed(ctx, "SBS", addr, reconnectDelay, func(ctx context.Context, r io.Reader) error {
+		return ReadSBS(ctx, r, tracker)
+	})
+}
@@ -0,0 +1,111 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSBS(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Update
+	}{
+		{
+			name: "identification",
+			line: "MSG,1,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,RYR123,,,,,,,,0,0,0,0",
+			want: Update{Callsign: "RYR123", OnGround: ptr(false)},
+		},
+		{
+			name: "airborne position",
+			line: "MSG,3,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,35000,,,53.3498,-6.2603,,,0,0,0,0\r\n",
+			want: Update{
+				Altitude:  ptr(10668),
+				Latitude:  ptr(53.3498),
+				Longitude: ptr(-6.2603),
+				OnGround:  ptr(false),
+			},
+		},
+		{
+			name: "airborne velocity",
+			line: "MSG,4,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,,450,90,,,-1024,,,,,",
+			want: Update{Speed: ptr(833), Track: ptr(90.0), VerticalRate: ptr(-19)},
+		},
+		{
+			name: "on the ground, dump1090 style",
+			line: "MSG,2,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,,12,270,53.4213,-6.2701,,7000,,,,-1",
+			want: Update{
+				Speed:     ptr(22),
+				Track:     ptr(270.0),
+				Latitude:  ptr(53.4213),
+				Longitude: ptr(-6.2701),
+				Squawk:    "7000",
+				OnGround:  ptr(true),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSBS(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Source != SourceSBS || got.ICAO24 != "4CA2D6" {
+				t.Errorf("got source %q ICAO24 %q", got.Source, got.ICAO24)
+			}
+			if want := time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local); !got.Time.Equal(want) {
+				t.Errorf("got time %s, want %s", got.Time, want)
+			}
+			if got.Callsign != tt.want.Callsign || got.Squawk != tt.want.Squawk {
+				t.Errorf("got callsign %q squawk %q, want %q %q",
+					got.Callsign, got.Squawk, tt.want.Callsign, tt.want.Squawk)
+			}
+			checkPtr(t, "latitude", got.Latitude, tt.want.Latitude)
+			checkPtr(t, "longitude", got.Longitude, tt.want.Longitude)
+			checkPtr(t, "track", got.Track, tt.want.Track)
+			checkPtr(t, "altitude", got.Altitude, tt.want.Altitude)
+			checkPtr(t, "speed", got.Speed, tt.want.Speed)
+			checkPtr(t, "vertical rate", got.VerticalRate, tt.want.VerticalRate)
+			checkPtr(t, "on ground", got.OnGround, tt.want.OnGround)
+		})
+	}
+}
+
+func TestParseSBSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want error
+	}{
+		{name: "status line", line: "STA,,5,179,400AE7,10103,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,RM", want: ErrNotTransmission},
+		{name: "selection change", line: "SEL,,496,2286,4CA4E5,27215,2010/02/19,18:06:07.710,2010/02/19,18:06:07.710,RYR1427", want: ErrNotTransmission},
+		{name: "too few fields", line: "MSG,3,1,1,4CA2D6,1,2024/01/15,12:00:00.000"},
+		{name: "unknown transmission type", line: "MSG,9,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,,,,,,,,,,,"},
+		{name: "bad hex ident", line: "MSG,3,1,1,4CA2,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,,,,,,,,,,,"},
+		{name: "bad latitude", line: "MSG,3,1,1,4CA2D6,1,2024/01/15,12:00:00.000,2024/01/15,12:00:00.000,,35000,,,north,-6.2603,,,,,,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSBS(tt.line)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func checkPtr[T comparable](t *testing.T, name string, got, want *T) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("got %s %v, want %v", name, got, want)
+	case *got != *want:
+		t.Errorf("got %s %v, want %v", name, *got, *want)
+	}
+}
@@ -0,0 +1,114 @@
+package ingest
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// staleAfter is how long an aircraft is kept without hearing from it.
+const staleAfter = 10 * time.Minute
+
+// Store writes tracked positions into the live columns of flights.
+type Store struct {
+	conn *pgxpool.Pool
+}
+
+func NewStore(conn *pgxpool.Pool) *Store {
+	return &Store{conn: conn}
+}
+
+// Coverage counts, for one source, the aircraft saved and how many of them
+// matched a flight.
+type Coverage struct {
+	Aircraft int
+	Matched  int
+	Flights  int64
+}
+
+// SaveLive updates the scheduled or active flights flown by each aircraft,
+// matched on aircraft_icao24 through its lower() index, and returns the
+// coverage per source, see Aircraft.CoveredBy.
+func (s *Store) SaveLive(ctx context.Context, aircraft []Aircraft) (map[string]*Coverage, error) {
+	coverage := make(map[string]*Coverage)
+	if len(aircraft) == 0 {
+		return coverage, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, a := range aircraft {
+		batch.Queue(`
+			update flights set
+				live_updated = $2, live_latitude = $3, live_longitude = $4, live_altitude = $5,
+				live_direction = $6, live_speed_horizontal = $7, live_speed_vertical = $8, live_is_ground = $9
+			where lower(aircraft_icao24) = $1
+				and flight_status in ('scheduled', 'active')
+				and flight_date >= to_char(now() - interval '1 day', 'YYYY-MM-DD')
+			`,
+			a.ICAO24, a.Live.LiveUpdated, a.Live.LiveLatitude, a.Live.LiveLongitude, a.Live.LiveAltitude,
+			int(a.Live.LiveDirection), a.Live.LiveSpeedHorizontal, a.Live.LiveSpeedVertical, a.Live.LiveIsGround,
+		)
+	}
+
+	results := s.conn.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, a := range aircraft {
+		tag, err := results.Exec()
+		if err != nil {
+			return coverage, err
+		}
+
+		for _, source := range a.CoveredBy() {
+			c, ok := coverage[source]
+			if !ok {
+				c = &Coverage{}
+				coverage[source] = c
+			}
+			c.Aircraft++
+			if tag.RowsAffected() > 0 {
+				c.Matched++
+				c.Flights += tag.RowsAffected()
+			}
+		}
+	}
+	return coverage, nil
+}
+
+// finalFlushTimeout bounds the flush made after Run is cancelled, so a stuck
+// database cannot hold up shutdown.
+const finalFlushTimeout = 5 * time.Second
+
+// Run flushes tracker every interval until ctx is done, then flushes once
+// more within finalFlushTimeout.
+func (s *Store) Run(ctx context.Context, tracker *Tracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			s.flush(flushCtx, tracker)
+			cancel()
+			return
+		case <-ticker.C:
+			s.flush(ctx, tracker)
+			tracker.Prune(time.Now().Add(-staleAfter))
+		}
+	}
+}
+
+func (s *Store) flush(ctx context.Context, tracker *Tracker) {
+	coverage, err := s.SaveLive(ctx, tracker.Drain())
+	if err != nil {
+		slog.Error("Error saving live positions", "error", err)
+		return
+	}
+	for source, c := range coverage {
+		slog.Info("Saved live positions",
+			"source", source, "aircraft", c.Aircraft, "matched", c.Matched, "flights", c.Flights)
+	}
+}
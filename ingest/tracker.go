@@ -0,0 +1,196 @@
+package ingest
+
+import (
+	"github.com/FACorreiaa/go-ollama/api/structs"
+	"maps"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Live feeds, recorded on each aircraft to compare their coverage.
+const (
+	SourceSBS     = "sbs"
+	SourceModeS   = "modes"
+	SourceOpenSky = "opensky"
+)
+
+// Update is a partial observation of one aircraft from a live feed. Nil
+// fields were not part of the message and leave the known state untouched.
+// Units match structs.Live: metres and km/h.
+type Update struct {
+	Source       string
+	ICAO24       string
+	Time         time.Time
+	Callsign     string
+	Squawk       string
+	Latitude     *float64
+	Longitude    *float64
+	Altitude     *int
+	Track        *float64
+	Speed        *int
+	VerticalRate *int
+	OnGround     *bool
+}
+
+// coverageWindow is how close to the latest message about an aircraft a
+// feed must have heard from it to count as covering it.
+const coverageWindow = time.Minute
+
+// Aircraft is the accumulated state of one transponder.
+type Aircraft struct {
+	// Sources holds when each feed last heard from the aircraft
+	Sources    map[string]time.Time
+	ICAO24     string
+	Callsign   string
+	Squawk     string
+	LastSeen   time.Time
+	Positioned bool
+	Live       structs.Live
+}
+
+// Tracker keeps the latest state per ICAO24 address. It is safe for
+// concurrent use so several feeds can share it.
+type Tracker struct {
+	mu       sync.Mutex
+	aircraft map[string]*Aircraft
+	changed  map[string]struct{}
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		aircraft: make(map[string]*Aircraft),
+		changed:  make(map[string]struct{}),
+	}
+}
+
+func (t *Tracker) Apply(u Update) {
+	icao24 := strings.ToLower(strings.TrimSpace(u.ICAO24))
+	if icao24 == "" {
+		return
+	}
+	if u.Time.IsZero() {
+		u.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.aircraft[icao24]
+	if !ok {
+		a = &Aircraft{ICAO24: icao24, Sources: make(map[string]time.Time)}
+		t.aircraft[icao24] = a
+	}
+	if u.Time.After(a.LastSeen) {
+		a.LastSeen = u.Time
+	}
+	if u.Time.After(a.Sources[u.Source]) {
+		a.Sources[u.Source] = u.Time
+	}
+
+	if callsign := strings.TrimSpace(u.Callsign); callsign != "" {
+		a.Callsign = callsign
+	}
+	if u.Squawk != "" {
+		a.Squawk = u.Squawk
+	}
+	if u.Latitude != nil && u.Longitude != nil {
+		a.Live.LiveLatitude = float32(*u.Latitude)
+		a.Live.LiveLongitude = float32(*u.Longitude)
+		a.Positioned = true
+	}
+	if u.Altitude != nil {
+		a.Live.LiveAltitude = *u.Altitude
+	}
+	if u.Track != nil {
+		a.Live.LiveDirection = float32(*u.Track)
+	}
+	if u.Speed != nil {
+		a.Live.LiveSpeedHorizontal = *u.Speed
+	}
+	if u.VerticalRate != nil {
+		a.Live.LiveSpeedVertical = *u.VerticalRate
+	}
+	if u.OnGround != nil {
+		a.Live.LiveIsGround = *u.OnGround
+	}
+	a.Live.LiveUpdated = a.LastSeen.UTC().Format(time.RFC3339)
+
+	t.changed[icao24] = struct{}{}
+}
+
+// CoveredBy returns the feeds that heard from the aircraft within
+// coverageWindow of its latest message, an aircraft several feeds pick up
+// counts for each of them.
+func (a Aircraft) CoveredBy() []string {
+	sources := make([]string, 0, len(a.Sources))
+	for source, seen := range a.Sources {
+		if a.LastSeen.Sub(seen) <= coverageWindow {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// clone copies a so it can be read outside the tracker's lock.
+func (a *Aircraft) clone() Aircraft {
+	c := *a
+	c.Sources = maps.Clone(a.Sources)
+	return c
+}
+
+// Aircraft returns a copy of the state for icao24.
+func (t *Tracker) Aircraft(icao24 string) (Aircraft, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.aircraft[strings.ToLower(icao24)]
+	if !ok {
+		return Aircraft{}, false
+	}
+	return a.clone(), true
+}
+
+// Drain returns the positioned aircraft that changed since the previous call.
+func (t *Tracker) Drain() []Aircraft {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := make([]Aircraft, 0, len(t.changed))
+	for icao24 := range t.changed {
+		if a := t.aircraft[icao24]; a.Positioned {
+			changed = append(changed, a.clone())
+		}
+		delete(t.changed, icao24)
+	}
+	return changed
+}
+
+// Prune forgets aircraft not heard from since before.
+func (t *Tracker) Prune(before time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pruned := 0
+	for icao24, a := range t.aircraft {
+		if a.LastSeen.Before(before) {
+			delete(t.aircraft, icao24)
+			delete(t.changed, icao24)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func feetToMetres(ft float64) int {
+	return int(math.Round(ft * 0.3048))
+}
+
+func knotsToKmh(kt float64) int {
+	return int(math.Round(kt * 1.852))
+}
+
+func feetPerMinuteToKmh(fpm float64) int {
+	return int(math.Round(fpm * 0.018288))
+}
@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTrackerSources(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+
+	tracker.Apply(Update{Source: SourceSBS, ICAO24: "4CA2D6", Time: start, Latitude: ptr(53.3), Longitude: ptr(-6.2)})
+	tracker.Apply(Update{Source: SourceOpenSky, ICAO24: "4ca2d6", Time: start.Add(10 * time.Second)})
+	// a late message does not move a feed's last sighting back
+	tracker.Apply(Update{Source: SourceSBS, ICAO24: "4ca2d6", Time: start.Add(-time.Minute)})
+
+	a, ok := tracker.Aircraft("4CA2D6")
+	if !ok {
+		t.Fatal("aircraft not tracked")
+	}
+	want := map[string]time.Time{SourceSBS: start, SourceOpenSky: start.Add(10 * time.Second)}
+	if len(a.Sources) != len(want) {
+		t.Fatalf("got sources %v, want %v", a.Sources, want)
+	}
+	for source, seen := range want {
+		if !a.Sources[source].Equal(seen) {
+			t.Errorf("got %s last seen %s, want %s", source, a.Sources[source], seen)
+		}
+	}
+	if !a.LastSeen.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("got last seen %s, want the latest message", a.LastSeen)
+	}
+
+	covered := a.CoveredBy()
+	slices.Sort(covered)
+	if want := []string{SourceOpenSky, SourceSBS}; !slices.Equal(covered, want) {
+		t.Errorf("got covered by %v, want %v", covered, want)
+	}
+
+	// the copy does not share the tracker's map
+	a.Sources[SourceModeS] = start
+	if again, _ := tracker.Aircraft("4ca2d6"); len(again.Sources) != 2 {
+		t.Errorf("got sources %v, want the tracker's state untouched", again.Sources)
+	}
+}
+
+func TestTrackerCoverageWindow(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+
+	tracker.Apply(Update{Source: SourceModeS, ICAO24: "4ca2d6", Time: start, Latitude: ptr(53.3), Longitude: ptr(-6.2)})
+	tracker.Apply(Update{Source: SourceOpenSky, ICAO24: "4ca2d6", Time: start.Add(coverageWindow + time.Second)})
+
+	drained := tracker.Drain()
+	if len(drained) != 1 {
+		t.Fatalf("got %d aircraft drained, want 1", len(drained))
+	}
+	if covered := drained[0].CoveredBy(); !slices.Equal(covered, []string{SourceOpenSky}) {
+		t.Errorf("got covered by %v, want only the feed that still hears the aircraft", covered)
+	}
+}
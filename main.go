@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/FACorreiaa/go-ollama/api"
 	"github.com/FACorreiaa/go-ollama/config"
 	"github.com/FACorreiaa/go-ollama/controller"
 	"github.com/FACorreiaa/go-ollama/db"
+	"github.com/FACorreiaa/go-ollama/ingest"
+	"github.com/FACorreiaa/go-ollama/ingest/modes"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -43,7 +48,7 @@ func main() {
 
 	db.WaitForDB(pool)
 
-	redisClient, err := db.InitRedis(cfg.Redis.Host, cfg.Redis.Password, cfg.Redis.Db)
+	redisClient, err := db.InitRedis(cfg.Redis.Host, cfg.Redis.Password, cfg.Redis.DB)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -57,6 +62,10 @@ func main() {
 	}(redisClient)
 	db.WaitForRedis(redisClient)
 
+	// cancelled on interrupt or SIGTERM, stopping live feeds
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if err = db.Migrate(pool); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -64,45 +73,101 @@ func main() {
 
 	startTime := time.Now()
 
-	tableDataMigration := api.NewRepository(pool)
-	if err = tableDataMigration.MigrateAirlineAPIData(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// quota tracking is opt-in, and replayed responses cost nothing to count
+	var quota *api.QuotaTracker
+	if cfg.AviationStack.MonthlyQuota > 0 && cfg.AviationStack.Mode != api.ModeReplay {
+		quota = api.NewQuotaTracker(pool, cfg.AviationStack)
 	}
 
-	if err = tableDataMigration.MigrateAircraftAPIData(); err != nil {
+	quarantine := api.NewQuarantine(pool)
+	aviationStackClient, err := api.NewAviationStackClient(
+		cfg.AviationStack, nil, api.NewResponseCache(redisClient, cfg.AviationStack), quota, quarantine,
+	)
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	if err = tableDataMigration.MigrateTaxAPIData(); err != nil {
+	referenceProvider, err := api.NewReferenceProvider(cfg.ReferenceData, aviationStackClient)
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	if err = tableDataMigration.MigrateAirplaneAPIData(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+	jobLedger := api.NewJobLedger(pool)
+	jobRepo := api.NewRepositoryJob(pool)
+	jobLocker := api.NewJobLocker(redisClient, cfg.Jobs.LockTTL)
+	jobRetries := api.NewRetryQueue(pool, cfg.Jobs)
+	jobService := api.NewServiceJob(
+		jobRepo, aviationStackClient, referenceProvider, jobLedger, jobLocker, jobRetries, cfg.Jobs,
+	)
 
-	if err = tableDataMigration.MigrateAirportAPIData(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// any arguments are a command, run instead of the server
+	if len(os.Args) > 1 {
+		err := runCommand(ctx, jobService, os.Args[1:])
+		if errors.Is(err, errUsage) {
+			fmt.Printf(usage, os.Args[0])
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	if err = tableDataMigration.MigrateCountryAPIData(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	var ourAirports *api.OurAirportsProvider
+	if cfg.ReferenceData.OurAirportsDir != "" {
+		ourAirports = api.NewOurAirportsProvider(cfg.ReferenceData.OurAirportsDir, quarantine)
 	}
 
-	if err = tableDataMigration.MigrateCityAPIData(); err != nil {
+	tableDataMigration := api.NewRepository(
+		pool, aviationStackClient, referenceProvider, ourAirports, cfg.Jobs.FlightsQuery,
+	)
+	// Reference data the server pages read is seeded before it starts, the
+	// rest is seeded in the background once it runs
+	seedSteps := []api.SeedStep{
+		{Name: "country", Critical: true, Run: tableDataMigration.MigrateCountryAPIData},
+		{Name: "city", After: []string{"country"}, Critical: true, Run: tableDataMigration.MigrateCityAPIData},
+		{Name: "airport", After: []string{"city"}, Critical: true, Run: tableDataMigration.MigrateAirportAPIData},
+		{Name: "airline", Critical: true, Run: tableDataMigration.MigrateAirlineAPIData},
+		{Name: "aircraft", Run: tableDataMigration.MigrateAircraftAPIData},
+		{Name: "tax", Run: tableDataMigration.MigrateTaxAPIData},
+		{Name: "airplane", Run: tableDataMigration.MigrateAirplaneAPIData},
+		{Name: "route", After: []string{"airport", "airline"}, Run: tableDataMigration.MigrateRouteAPIData},
+	}
+	if ourAirports != nil {
+		seedSteps = append(seedSteps, api.SeedStep{
+			Name: "ourairports", After: []string{"airport"},
+			Datasets: []string{"runway", "airport_frequency", "navaid"},
+			Run:      tableDataMigration.MigrateOurAirportsData,
+		})
+	}
+	// flights are only seeded when they are polled, without FLIGHTS_QUERY
+	// that would walk every page of /flights
+	if cfg.Jobs.Datasets["flights"].Enabled {
+		seedSteps = append(seedSteps, api.SeedStep{
+			Name: "flight", After: []string{"airport", "airline"},
+			Datasets: []string{"flights"},
+			Run:      tableDataMigration.MigrateFlightAPIData,
+		})
+	}
+	seeder, err := api.NewSeeder(jobLedger, jobLocker, api.NewSeedCheckpoints(pool), cfg.Jobs, seedSteps)
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	if err = tableDataMigration.MigrateFlightAPIData(); err != nil {
+	// background seeding gets GracefulTimeout to finish once shutdown
+	// starts, a signal before the server is up stops it right away
+	seedCtx, cancelSeed := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSeed()
+	stopSeedOnSignal := context.AfterFunc(ctx, cancelSeed)
+	seeder.Start(seedCtx)
+	if err := seeder.WaitCritical(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	stopSeedOnSignal()
 
 	fmt.Println("This operation took: ", time.Since(startTime))
 
@@ -111,35 +176,143 @@ func main() {
 	//	os.Exit(1)
 	//}
 
+	// sync jobs get GracefulTimeout to finish once shutdown starts
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	if err := jobService.StartAPICheckCronJob(jobsCtx); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	srv := &http.Server{
 		Addr:         cfg.Server.Addr,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
-		Handler:      controller.Router(pool, []byte(cfg.Server.SessionKey), redisClient),
+		Handler: controller.Router(
+			pool, []byte(cfg.Server.SessionKey), cfg.Server.AdminEmails, redisClient, quota, jobService,
+		),
 	}
 
-	jobRepo := api.NewRepositoryJob(pool)
-
-	jobService := api.NewServiceJob(jobRepo)
-
-	jobService.StartAPICheckCronJob()
-
 	go func() {
 		slog.Info("Starting server " + cfg.Server.Addr)
-		if err := srv.ListenAndServe(); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("ListenAndServe", "error", err)
 		}
 	}()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	ingestDone := startIngest(ctx, cfg.Ingest, pool)
 
-	//shutdown server
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulTimeout)
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down", "timeout", cfg.Server.GracefulTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulTimeout)
 	defer cancel()
-	srv.Shutdown(ctx)
-	slog.Info("shutting down")
+
+	clean := true
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server did not shut down cleanly", "error", err)
+		clean = false
+	}
+
+	if err := jobService.Stop(shutdownCtx); err != nil {
+		slog.Warn("Cancelling running jobs", "error", err)
+		clean = false
+		cancelJobs()
+		// give cancelled jobs a moment to record their run
+		waitCtx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := jobService.Wait(waitCtx); err != nil {
+			slog.Error("Jobs did not stop", "error", err)
+		}
+		cancelWait()
+	}
+
+	if err := seeder.Wait(shutdownCtx); err != nil {
+		slog.Warn("Cancelling background seeding", "error", err)
+		clean = false
+		cancelSeed()
+		// give cancelled seeds a moment to record their run
+		waitCtx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := seeder.Wait(waitCtx); err != nil {
+			slog.Error("Background seeding did not stop", "error", err)
+		}
+		cancelWait()
+	}
+
+	select {
+	case <-ingestDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Live positions were not flushed", "error", shutdownCtx.Err())
+		clean = false
+	}
+
+	pool.Close()
+	if err := redisClient.Close(); err != nil {
+		slog.Error("Error closing Redis", "error", err)
+		clean = false
+	}
+
+	if !clean {
+		slog.Warn("Shutdown finished with errors")
+		os.Exit(1)
+	}
+	slog.Info("Shutdown complete")
 	os.Exit(0)
 }
+
+// startIngest starts the configured live position feeds and the writer that
+// stores their positions. The returned channel is closed once the writer has
+// made its last flush after ctx is done.
+func startIngest(ctx context.Context, cfg *config.IngestConfig, pool *pgxpool.Pool) <-chan struct{} {
+	done := make(chan struct{})
+	if cfg.SBSAddr == "" && cfg.SBSFile == "" && cfg.ModeSAddr == "" && cfg.ModeSFile == "" &&
+		cfg.OpenSkyURL == "" && cfg.OpenSkyFile == "" {
+		close(done)
+		return done
+	}
+
+	tracker := ingest.NewTracker()
+	go func() {
+		defer close(done)
+		ingest.NewStore(pool).Run(ctx, tracker, cfg.FlushInterval)
+	}()
+
+	if cfg.SBSFile != "" {
+		go func() {
+			if err := ingest.ReadSBSFile(ctx, cfg.SBSFile, tracker); err != nil {
+				slog.Error("Error reading SBS file", "error", err)
+			}
+		}()
+	}
+	if cfg.SBSAddr != "" {
+		go ingest.RunSBS(ctx, cfg.SBSAddr, cfg.ReconnectDelay, tracker)
+	}
+
+	var receiver *modes.Position
+	if cfg.ReceiverLatitude != nil && cfg.ReceiverLongitude != nil {
+		receiver = &modes.Position{Latitude: *cfg.ReceiverLatitude, Longitude: *cfg.ReceiverLongitude}
+	}
+	decoder := modes.NewDecoder(receiver)
+	if cfg.ModeSFile != "" {
+		go func() {
+			if err := ingest.ReadModeSFile(ctx, cfg.ModeSFile, cfg.ModeSFormat, decoder, tracker); err != nil {
+				slog.Error("Error reading Mode-S file", "error", err)
+			}
+		}()
+	}
+	if cfg.ModeSAddr != "" {
+		go ingest.RunModeS(ctx, cfg.ModeSAddr, cfg.ModeSFormat, cfg.ReconnectDelay, decoder, tracker)
+	}
+
+	if cfg.OpenSkyFile != "" {
+		if err := ingest.ReadOpenSkyFile(cfg.OpenSkyFile, tracker); err != nil {
+			slog.Error("Error reading OpenSky file", "error", err)
+		}
+	}
+	if cfg.OpenSkyURL != "" {
+		poller := ingest.NewOpenSkyPoller(cfg.OpenSkyURL, cfg.OpenSkyUsername, cfg.OpenSkyPassword, nil)
+		go poller.Run(ctx, cfg.OpenSkyInterval, tracker)
+	}
+	return done
+}